@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrWriterClosed = errors.New("async writer: closed")
+
+// AsyncWriterConfig decides when a batch is committed. A batch goes out when
+// it reaches MaxBatch bytes, when its oldest write has waited MaxDelay, or -
+// with MaxDelay zero - as soon as nobody else is queued behind it.
+type AsyncWriterConfig struct {
+	MaxBatch  int           // Bytes per batch, also the bufio size; defaults to 64KB
+	MaxDelay  time.Duration // Longest a write sits in an open batch
+	QueueSize int           // Writes in flight before callers block; defaults to 1024
+	Sync      bool          // Group commit: one Sync per batch, futures resolve after it
+}
+
+// syncer is the part of *os.File that makes a batch durable
+type syncer interface {
+	Sync() error
+}
+
+// WriteFuture resolves when its write has been committed - written, and
+// synced too if the writer was configured to
+type WriteFuture struct {
+	done chan struct{}
+	err  error
+}
+
+// Done is closed once the write is committed or has failed
+func (f *WriteFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the write is committed and returns its error
+func (f *WriteFuture) Wait() error {
+	<-f.done
+	return f.err
+}
+
+func (f *WriteFuture) resolve(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// AsyncWriterStats is a snapshot of what the writer has done so far
+type AsyncWriterStats struct {
+	Writes  uint64
+	Bytes   uint64
+	Batches uint64 // Commits that wrote something
+	Syncs   uint64
+}
+
+type asyncRequest struct {
+	data   []byte // Nil for a Flush marker
+	future *WriteFuture
+}
+
+// AsyncWriter takes writes from any number of goroutines and hands them to
+// one goroutine that owns the bufio.Writer, so callers never wait for the
+// disk unless they ask to
+type AsyncWriter struct {
+	cfg  AsyncWriterConfig
+	out  *bufio.Writer
+	sync syncer // Nil when the destination can't sync
+	reqs chan asyncRequest
+
+	mu     sync.RWMutex // Writers hold it shared so Close can close reqs safely
+	closed bool
+	once   sync.Once
+	exited chan struct{}
+	err    error // First write or sync error; sticky, owned by the loop until exited
+
+	writes  atomic.Uint64
+	bytes   atomic.Uint64
+	batches atomic.Uint64
+	syncs   atomic.Uint64
+}
+
+// NewAsyncWriter starts the committing goroutine. If w has a Sync method
+// and cfg.Sync is set, every batch is synced before its futures resolve.
+func NewAsyncWriter(w io.Writer, cfg AsyncWriterConfig) *AsyncWriter {
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 64 << 10
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+
+	a := &AsyncWriter{
+		cfg:    cfg,
+		out:    bufio.NewWriterSize(w, cfg.MaxBatch),
+		reqs:   make(chan asyncRequest, cfg.QueueSize),
+		exited: make(chan struct{}),
+	}
+	if s, ok := w.(syncer); ok && cfg.Sync {
+		a.sync = s
+	}
+	go a.loop()
+	return a
+}
+
+// Write queues a copy of p and returns straight away. Errors show up in a
+// later Write, Flush or Close; use WriteAsync to wait on this one.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	f := a.WriteAsync(p)
+	select {
+	case <-f.Done():
+		if f.err != nil {
+			return 0, f.err
+		}
+	default:
+	}
+	return len(p), nil
+}
+
+// WriteAsync queues a copy of p and returns a future for its commit
+func (a *AsyncWriter) WriteAsync(p []byte) *WriteFuture {
+	return a.submit(asyncRequest{data: append([]byte(nil), p...)})
+}
+
+// Flush commits everything queued before it and waits for that commit
+func (a *AsyncWriter) Flush() error {
+	return a.submit(asyncRequest{}).Wait()
+}
+
+func (a *AsyncWriter) submit(req asyncRequest) *WriteFuture {
+	req.future = &WriteFuture{done: make(chan struct{})}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		req.future.resolve(ErrWriterClosed)
+		return req.future
+	}
+	a.reqs <- req
+	return req.future
+}
+
+// Close drains every queued write, commits them and stops the writer. It
+// returns the first error the writer hit. Closing twice is harmless.
+func (a *AsyncWriter) Close() error {
+	a.once.Do(func() {
+		a.mu.Lock() // Waits out any submit mid-send
+		a.closed = true
+		close(a.reqs)
+		a.mu.Unlock()
+	})
+	<-a.exited
+	return a.err
+}
+
+// Stats returns a snapshot of the writer's counters
+func (a *AsyncWriter) Stats() AsyncWriterStats {
+	return AsyncWriterStats{
+		Writes:  a.writes.Load(),
+		Bytes:   a.bytes.Load(),
+		Batches: a.batches.Load(),
+		Syncs:   a.syncs.Load(),
+	}
+}
+
+// loop is the only goroutine that touches out
+func (a *AsyncWriter) loop() {
+	defer close(a.exited)
+
+	var pending []*WriteFuture // Resolved by the next commit
+	batchBytes := 0            // Written since the last commit, some maybe already spilled by bufio
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	var deadline <-chan time.Time
+
+	commit := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if batchBytes > 0 {
+			a.commitBatch()
+			batchBytes = 0
+		}
+		for _, f := range pending {
+			f.resolve(a.err)
+		}
+		pending = pending[:0]
+		timer.Stop()
+		deadline = nil
+	}
+
+	for {
+		select {
+		case req, ok := <-a.reqs:
+			if !ok {
+				commit() // Closed and drained
+				return
+			}
+			pending = append(pending, req.future)
+			if req.data == nil {
+				commit() // Flush marker
+				continue
+			}
+
+			if a.err == nil {
+				a.out.Write(req.data)
+			}
+			batchBytes += len(req.data)
+			a.writes.Add(1)
+			a.bytes.Add(uint64(len(req.data)))
+
+			switch {
+			case batchBytes >= a.cfg.MaxBatch:
+				commit() // Full - bufio may already have spilled part of it
+			case a.cfg.MaxDelay == 0 && len(a.reqs) == 0:
+				commit() // Nobody queued behind us - no point waiting
+			case deadline == nil && a.cfg.MaxDelay > 0:
+				timer.Reset(a.cfg.MaxDelay)
+				deadline = timer.C
+			}
+		case <-deadline:
+			deadline = nil
+			commit()
+		}
+	}
+}
+
+// commitBatch writes out the buffer and, for group commit, syncs it once
+// for every write in the batch
+func (a *AsyncWriter) commitBatch() {
+	if a.err != nil {
+		return
+	}
+	a.batches.Add(1)
+	if err := a.out.Flush(); err != nil {
+		a.err = err
+		return
+	}
+	if a.sync != nil {
+		a.syncs.Add(1)
+		if err := a.sync.Sync(); err != nil {
+			a.err = err
+		}
+	}
+}
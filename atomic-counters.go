@@ -1,24 +1,43 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
 
 func main() {
+	trials := flag.Int("trials", 0, "run the racy counter this many times per configuration and report lost-update statistics")
+	child := flag.Bool("child", false, "internal: run one racy trial and print the missing increments")
+	goroutines := flag.Int("goroutines", 50, "goroutines per racy trial (used with -child)")
+	flag.Parse()
+
+	if *child {
+		// One fresh process, one sample - no warm caches from earlier trials
+		fmt.Println(*goroutines*1000 - int(racyCount(*goroutines, 1000)))
+		return
+	}
+	if *trials > 0 {
+		runLostUpdateTrials(*trials)
+		return
+	}
+
 	// The protected one - like Wu Zetian's Iron Widow mech, synchronized and lethal
 	var atomicOps atomic.Uint64
 
-	// The unprotected one - like Eve Brown without her sisters, chaotic and vulnerable
-	var regularOps uint64
-
 	var wg sync.WaitGroup
 
 	fmt.Println("🕯️ Summoning 50 goroutines to increment 1000 times each...")
 	fmt.Println("Expected: 50,000 for both. Reality? *cackles in data race*\n")
 
 	// First, the atomic ritual
+	probe := StartSchedProbe()
 	for i := 0; i < 50; i++ {
 		wg.Add(1)
 		go func() {
@@ -29,23 +48,87 @@ func main() {
 		}()
 	}
 	wg.Wait()
-
-	// Reset for the chaos demon (regular int)
-	wg = sync.WaitGroup{}
+	atomicSched := probe.Stop()
 
 	// Now the unprotected variable - like going to investigate that noise alone
-	for i := 0; i < 50; i++ {
+	probe = StartSchedProbe()
+	regularOps := racyCount(50, 1000)
+	racySched := probe.Stop()
+
+	fmt.Printf("⚡ Atomic ops (protected by eldritch synchronization): %d\n", atomicOps.Load())
+	fmt.Println(atomicSched.Summary())
+	fmt.Printf("👻 Regular ops (raw dogging concurrency): %d\n", regularOps)
+	fmt.Println(racySched.Summary())
+	fmt.Printf("\n💀 Data corruption level: %d missing increments\n", 50000-int(regularOps))
+}
+
+// racyCount runs the unprotected increment loop and returns what survived
+func racyCount(goroutines, increments int) uint64 {
+	// The unprotected one - like Eve Brown without her sisters, chaotic and vulnerable
+	var regularOps uint64
+	var wg sync.WaitGroup
+
+	for i := 0; i < goroutines; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for j := 0; j < 1000; j++ {
+			for j := 0; j < increments; j++ {
 				regularOps++ // This is giving "first to die in a horror movie" energy
 			}
 		}()
 	}
 	wg.Wait()
 
-	fmt.Printf("⚡ Atomic ops (protected by eldritch synchronization): %d\n", atomicOps.Load())
-	fmt.Printf("👻 Regular ops (raw dogging concurrency): %d\n", regularOps)
-	fmt.Printf("\n💀 Data corruption level: %d missing increments\n", 50000-int(regularOps))
+	return regularOps
+}
+
+// runLostUpdateTrials re-executes this program once per trial across a grid
+// of GOMAXPROCS and goroutine counts, and summarizes the missing increments
+func runLostUpdateTrials(trials int) {
+	self, err := os.Executable()
+	if err != nil {
+		panic("Cannot find our own body to possess: " + err.Error())
+	}
+
+	var procs []int
+	for p := 1; p < runtime.NumCPU(); p *= 2 {
+		procs = append(procs, p)
+	}
+	procs = append(procs, runtime.NumCPU())
+	goroutineCounts := []int{2, 8, 50, 200}
+
+	fmt.Println("🩸 LOST-UPDATE SÉANCE 🩸")
+	fmt.Printf("%d fresh child processes per configuration, 1000 increments per goroutine\n\n", trials)
+	fmt.Printf("%-11s %-11s %12s %10s %10s %10s\n",
+		"GOMAXPROCS", "goroutines", "mean lost", "mean %", "max lost", "P(zero)")
+
+	for _, p := range procs {
+		for _, g := range goroutineCounts {
+			var sum, worst, clean int
+			for t := 0; t < trials; t++ {
+				cmd := exec.Command(self, "-child", "-goroutines", strconv.Itoa(g))
+				cmd.Env = append(os.Environ(), "GOMAXPROCS="+strconv.Itoa(p))
+				out, err := cmd.Output()
+				if err != nil {
+					panic("Child séance failed: " + err.Error())
+				}
+				lost, err := strconv.Atoi(strings.TrimSpace(string(out)))
+				if err != nil {
+					panic("Child spoke in tongues: " + string(out))
+				}
+
+				sum += lost
+				worst = max(worst, lost)
+				if lost == 0 {
+					clean++
+				}
+			}
+
+			mean := float64(sum) / float64(trials)
+			fmt.Printf("%-11d %-11d %12.1f %9.2f%% %10d %10.3f\n",
+				p, g, mean, 100*mean/float64(g*1000), worst, float64(clean)/float64(trials))
+		}
+	}
+
+	fmt.Println("\n💀 P(zero) is how often the race hides completely - the \"works on my machine\" probability")
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// CAS-loop primitives built on atomic.Int64/Uint64.CompareAndSwap.
+// Every exported method has an unexported twin that also reports how many
+// times the loop lost the race and had to retry - cas_loops.go uses those
+// to show when a CAS loop starts spinning.
+
+// AtomicMax remembers the largest value ever offered to it
+type AtomicMax struct {
+	v atomic.Int64
+}
+
+// NewAtomicMax creates a max starting at math.MinInt64
+func NewAtomicMax() *AtomicMax {
+	m := &AtomicMax{}
+	m.v.Store(math.MinInt64)
+	return m
+}
+
+// Offer raises the max to x and reports whether x became the new max
+func (m *AtomicMax) Offer(x int64) bool {
+	ok, _ := m.offer(x)
+	return ok
+}
+
+func (m *AtomicMax) offer(x int64) (bool, int) {
+	for retries := 0; ; retries++ {
+		cur := m.v.Load()
+		if x <= cur {
+			return false, retries // Someone already summoned something bigger
+		}
+		if m.v.CompareAndSwap(cur, x) {
+			return true, retries
+		}
+	}
+}
+
+// Load returns the current max
+func (m *AtomicMax) Load() int64 {
+	return m.v.Load()
+}
+
+// AtomicMin remembers the smallest value ever offered to it
+type AtomicMin struct {
+	v atomic.Int64
+}
+
+// NewAtomicMin creates a min starting at math.MaxInt64
+func NewAtomicMin() *AtomicMin {
+	m := &AtomicMin{}
+	m.v.Store(math.MaxInt64)
+	return m
+}
+
+// Offer lowers the min to x and reports whether x became the new min
+func (m *AtomicMin) Offer(x int64) bool {
+	ok, _ := m.offer(x)
+	return ok
+}
+
+func (m *AtomicMin) offer(x int64) (bool, int) {
+	for retries := 0; ; retries++ {
+		cur := m.v.Load()
+		if x >= cur {
+			return false, retries
+		}
+		if m.v.CompareAndSwap(cur, x) {
+			return true, retries
+		}
+	}
+}
+
+// Load returns the current min
+func (m *AtomicMin) Load() int64 {
+	return m.v.Load()
+}
+
+// SaturatingCounter counts between 0 and a ceiling without wrapping
+type SaturatingCounter struct {
+	v     atomic.Uint64
+	limit uint64
+}
+
+// NewSaturatingCounter creates a counter that sticks at limit
+func NewSaturatingCounter(limit uint64) *SaturatingCounter {
+	return &SaturatingCounter{limit: limit}
+}
+
+// Add increases the counter by delta, clamped at the limit, and returns the new value
+func (c *SaturatingCounter) Add(delta uint64) uint64 {
+	n, _ := c.add(delta)
+	return n
+}
+
+func (c *SaturatingCounter) add(delta uint64) (uint64, int) {
+	for retries := 0; ; retries++ {
+		cur := c.v.Load()
+		next := c.limit
+		if delta < c.limit-cur {
+			next = cur + delta
+		}
+		if next == cur || c.v.CompareAndSwap(cur, next) {
+			return next, retries
+		}
+	}
+}
+
+// Sub decreases the counter by delta, clamped at zero, and returns the new value
+func (c *SaturatingCounter) Sub(delta uint64) uint64 {
+	for {
+		cur := c.v.Load()
+		next := uint64(0)
+		if delta < cur {
+			next = cur - delta
+		}
+		if next == cur || c.v.CompareAndSwap(cur, next) {
+			return next
+		}
+	}
+}
+
+// Load returns the current count
+func (c *SaturatingCounter) Load() uint64 {
+	return c.v.Load()
+}
+
+// BoundedCounter is a non-blocking counting semaphore: at most limit units
+// can be held at once
+type BoundedCounter struct {
+	inUse atomic.Int64
+	limit int64
+}
+
+// NewBoundedCounter creates a semaphore with limit units
+func NewBoundedCounter(limit int64) *BoundedCounter {
+	return &BoundedCounter{limit: limit}
+}
+
+// TryAcquire takes n units if they are all available
+func (b *BoundedCounter) TryAcquire(n int64) bool {
+	ok, _ := b.tryAcquire(n)
+	return ok
+}
+
+func (b *BoundedCounter) tryAcquire(n int64) (bool, int) {
+	for retries := 0; ; retries++ {
+		cur := b.inUse.Load()
+		if cur+n > b.limit {
+			return false, retries // The coven is full
+		}
+		if b.inUse.CompareAndSwap(cur, cur+n) {
+			return true, retries
+		}
+	}
+}
+
+// Release gives back n units taken by TryAcquire. Releasing more than is
+// held panics and leaves the count as it was.
+func (b *BoundedCounter) Release(n int64) {
+	for {
+		cur := b.inUse.Load()
+		if cur-n < 0 {
+			panic("BoundedCounter: released more than was acquired")
+		}
+		if b.inUse.CompareAndSwap(cur, cur-n) {
+			return
+		}
+	}
+}
+
+// InUse returns how many units are currently held
+func (b *BoundedCounter) InUse() int64 {
+	return b.inUse.Load()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Run with: go test -race cas_atomics.go cas_atomics_test.go
+
+// runFifty is the classic 50 goroutines x 1000 iterations loop
+func runFifty(op func(id, i int)) {
+	var wg sync.WaitGroup
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				op(id, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestAtomicMax(t *testing.T) {
+	m := NewAtomicMax()
+	runFifty(func(id, i int) { m.Offer(int64(id*1000 + i)) })
+	if got := m.Load(); got != 49999 {
+		t.Fatalf("max = %d, want 49999", got)
+	}
+}
+
+func TestAtomicMin(t *testing.T) {
+	m := NewAtomicMin()
+	runFifty(func(id, i int) { m.Offer(int64(id*1000 + i)) })
+	if got := m.Load(); got != 0 {
+		t.Fatalf("min = %d, want 0", got)
+	}
+}
+
+func TestSaturatingCounter(t *testing.T) {
+	c := NewSaturatingCounter(30000)
+	runFifty(func(int, int) { c.Add(1) })
+	if got := c.Load(); got != 30000 {
+		t.Fatalf("after 50000 adds count = %d, want the limit 30000", got)
+	}
+	runFifty(func(int, int) { c.Sub(1) })
+	if got := c.Load(); got != 0 {
+		t.Fatalf("after 50000 subs count = %d, want 0", got)
+	}
+}
+
+func TestBoundedCounter(t *testing.T) {
+	const limit = 10
+	b := NewBoundedCounter(limit)
+	peak := NewAtomicMax()
+	var holders atomic.Int64
+
+	runFifty(func(int, int) {
+		if !b.TryAcquire(1) {
+			return
+		}
+		peak.Offer(holders.Add(1))
+		runtime.Gosched() // Hold the unit long enough for others to pile up
+		holders.Add(-1)
+		b.Release(1)
+	})
+
+	if got := peak.Load(); got > limit {
+		t.Fatalf("%d holders at once, limit is %d", got, limit)
+	}
+	if got := b.InUse(); got != 0 {
+		t.Fatalf("%d units still held after everyone released", got)
+	}
+}
+
+func TestBoundedCounterOverRelease(t *testing.T) {
+	b := NewBoundedCounter(10)
+	if !b.TryAcquire(3) {
+		t.Fatal("TryAcquire(3) failed on an empty counter")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("releasing 4 of 3 held units didn't panic")
+			}
+		}()
+		b.Release(4)
+	}()
+
+	if got := b.InUse(); got != 3 {
+		t.Fatalf("in use after the bad release = %d, want 3 untouched", got)
+	}
+	b.Release(3)
+	if !b.TryAcquire(10) {
+		t.Fatal("the full limit isn't available after releasing everything")
+	}
+}
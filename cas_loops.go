@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	fmt.Println("🔮 CAS LOOP RITUALS 🔮")
+	fmt.Println(strings.Repeat("⚡", 30))
+
+	fmt.Println("\n(The primitives are checked under -race by go test -race cas_atomics.go cas_atomics_test.go)")
+
+	fmt.Println("\n🩸 How hard do CAS loops spin under contention?")
+	fmt.Printf("GOMAXPROCS=%d, 1000 ops per goroutine\n\n", runtime.GOMAXPROCS(0))
+	fmt.Printf("%-10s %14s %14s %14s %14s\n",
+		"goroutines", "Add ns/op", "max ns/op", "max retry/op", "sat retry/op")
+
+	for _, g := range []int{1, 2, 4, 8, 16, 32, 64, 128, 256} {
+		addTime := benchPlainAdd(g, 1000)
+		maxTime, maxRetries := benchAtomicMax(g, 1000)
+		_, satRetries := benchSaturatingAdd(g, 1000)
+
+		ops := float64(g * 1000)
+		fmt.Printf("%-10d %14.2f %14.2f %14.3f %14.3f\n", g,
+			float64(addTime.Nanoseconds())/ops,
+			float64(maxTime.Nanoseconds())/ops,
+			float64(maxRetries)/ops,
+			float64(satRetries)/ops)
+	}
+
+	fmt.Println("\n💀 Plain Add never retries - the hardware does the loop for you.")
+	fmt.Println("A CAS loop re-reads and tries again every time another goroutine wins,")
+	fmt.Println("like Wu Zetian's pilots all lunging for the same cockpit.")
+}
+
+func benchPlainAdd(g, ops int) time.Duration {
+	var n atomic.Int64
+	d, _ := timeGoroutines(g, func(int) int {
+		for i := 0; i < ops; i++ {
+			n.Add(1)
+		}
+		return 0
+	})
+	return d
+}
+
+// benchAtomicMax offers ever-increasing values, so nearly every offer wins
+// and every goroutine fights over the same word
+func benchAtomicMax(g, ops int) (time.Duration, int64) {
+	m := NewAtomicMax()
+	return timeGoroutines(g, func(id int) int {
+		retries := 0
+		for i := 0; i < ops; i++ {
+			_, n := m.offer(int64(i*g + id))
+			retries += n
+		}
+		return retries
+	})
+}
+
+func benchSaturatingAdd(g, ops int) (time.Duration, int64) {
+	c := NewSaturatingCounter(^uint64(0))
+	return timeGoroutines(g, func(int) int {
+		retries := 0
+		for i := 0; i < ops; i++ {
+			_, n := c.add(1)
+			retries += n
+		}
+		return retries
+	})
+}
+
+// timeGoroutines runs work on g goroutines and sums what they return
+func timeGoroutines(g int, work func(id int) int) (time.Duration, int64) {
+	var wg sync.WaitGroup
+	var total atomic.Int64
+	start := time.Now()
+	for w := 0; w < g; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			total.Add(int64(work(id)))
+		}(w)
+	}
+	wg.Wait()
+	return time.Since(start), total.Load()
+}
@@ -1,22 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
-// SafeMap wraps a map with a mutex for thread-safe access
+// SafeMap wraps a map with a mutex for thread-safe access.
+// The mutex is a CtxMutex so callers can refuse to wait on a stuck holder.
 type SafeMap struct {
-	mu sync.Mutex
-	m  map[int]int
+	mu    CtxMutex
+	m     map[int]int
+	stats LockStats
 }
 
 // NewSafeMap creates a new thread-safe map
 func NewSafeMap() *SafeMap {
-	return &SafeMap{
+	sm := &SafeMap{
 		m: make(map[int]int),
 	}
+	sm.mu.Observe(&sm.stats)
+	return sm
 }
 
 // Set safely writes a key-value pair to the map
@@ -26,6 +32,34 @@ func (sm *SafeMap) Set(key, value int) {
 	sm.mu.Unlock()
 }
 
+// Get safely reads a key from the map
+func (sm *SafeMap) Get(key int) (int, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	v, ok := sm.m[key]
+	return v, ok
+}
+
+// SetCtx is Set, but gives up with ctx.Err() instead of waiting forever
+func (sm *SafeMap) SetCtx(ctx context.Context, key, value int) error {
+	if err := sm.mu.LockContext(ctx); err != nil {
+		return err
+	}
+	sm.m[key] = value
+	sm.mu.Unlock()
+	return nil
+}
+
+// GetCtx is Get, but gives up with ctx.Err() instead of waiting forever
+func (sm *SafeMap) GetCtx(ctx context.Context, key int) (int, bool, error) {
+	if err := sm.mu.LockContext(ctx); err != nil {
+		return 0, false, err
+	}
+	defer sm.mu.Unlock()
+	v, ok := sm.m[key]
+	return v, ok, nil
+}
+
 // Len safely returns the length of the map
 func (sm *SafeMap) Len() int {
 	sm.mu.Lock()
@@ -35,7 +69,7 @@ func (sm *SafeMap) Len() int {
 
 // SafeMapRW uses RWMutex for potentially better read performance
 type SafeMapRW struct {
-	mu sync.RWMutex
+	mu InstrumentedRWMutex
 	m  map[int]int
 }
 
@@ -93,12 +127,17 @@ func main() {
 	// Single-threaded baseline
 	fmt.Println("=== Single-Threaded Baseline ===")
 	runSingleThreaded()
+
+	// A holder that never lets go
+	fmt.Println("\n=== Stuck Lock Holder ===")
+	runStuckHolder()
 }
 
 func runMutexExperiment(runNumber int) time.Duration {
 	sm := NewSafeMap()
 	var wg sync.WaitGroup
 
+	probe := StartSchedProbe()
 	start := time.Now()
 
 	for g := 0; g < 50; g++ {
@@ -113,9 +152,12 @@ func runMutexExperiment(runNumber int) time.Duration {
 
 	wg.Wait()
 	duration := time.Since(start)
+	sched := probe.Stop()
 
 	fmt.Printf("Run %d: len(m) = %d, time: %.2fms\n",
 		runNumber, sm.Len(), float64(duration.Microseconds())/1000.0)
+	fmt.Println(sm.stats.Summary())
+	fmt.Println(sched.Summary())
 
 	return duration
 }
@@ -124,6 +166,7 @@ func runRWMutexExperiment(runNumber int) time.Duration {
 	sm := NewSafeMapRW()
 	var wg sync.WaitGroup
 
+	probe := StartSchedProbe()
 	start := time.Now()
 
 	for g := 0; g < 50; g++ {
@@ -138,9 +181,12 @@ func runRWMutexExperiment(runNumber int) time.Duration {
 
 	wg.Wait()
 	duration := time.Since(start)
+	sched := probe.Stop()
 
 	fmt.Printf("Run %d: len(m) = %d, time: %.2fms\n",
 		runNumber, sm.Len(), float64(duration.Microseconds())/1000.0)
+	fmt.Println(sm.mu.WriteStats().Summary())
+	fmt.Println(sched.Summary())
 
 	return duration
 }
@@ -149,6 +195,7 @@ func runSyncMapExperiment(runNumber int) time.Duration {
 	var m sync.Map
 	var wg sync.WaitGroup
 
+	probe := StartSchedProbe()
 	start := time.Now()
 
 	for g := 0; g < 50; g++ {
@@ -163,6 +210,7 @@ func runSyncMapExperiment(runNumber int) time.Duration {
 
 	wg.Wait()
 	duration := time.Since(start)
+	sched := probe.Stop()
 
 	// Count entries
 	count := 0
@@ -173,6 +221,7 @@ func runSyncMapExperiment(runNumber int) time.Duration {
 
 	fmt.Printf("Run %d: entries = %d, time: %.2fms\n",
 		runNumber, count, float64(duration.Microseconds())/1000.0)
+	fmt.Println(sched.Summary())
 
 	return duration
 }
@@ -193,3 +242,23 @@ func runSingleThreaded() {
 	fmt.Printf("Single-threaded: len(m) = %d, time: %.2fms\n",
 		len(m), float64(duration.Microseconds())/1000.0)
 }
+
+func runStuckHolder() {
+	sm := NewSafeMap()
+	sm.Set(1, 1)
+
+	sm.mu.Lock() // Someone grabbed the lock and wandered off into the woods
+	defer sm.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := sm.SetCtx(ctx, 2, 2)
+	fmt.Printf("SetCtx: %v after %.2fms\n", err, float64(time.Since(start).Microseconds())/1000.0)
+
+	_, _, err = sm.GetCtx(ctx, 1)
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Println("GetCtx: gave up too instead of hanging forever")
+	}
+}
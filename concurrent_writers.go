@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logStrategy is one way for many goroutines to share a log file. run
+// writes lines lines from each of writers goroutines into path.
+type logStrategy struct {
+	name string
+	run  func(path string, writers, lines int) error
+}
+
+var logStrategies = []logStrategy{
+	{"mutex + shared bufio.Writer", mutexWriter},
+	{"channel funnel, one writer", channelFunnel},
+	{"per-goroutine buffer, O_APPEND", appendBatches},
+	{"file per goroutine, merged", filePerWriter},
+}
+
+// lineBytes is the size of every log line, newline included
+var lineBytes = 100
+
+func main() {
+	writers := flag.Int("writers", 50, "goroutines writing to the log")
+	lines := flag.Int("lines", 1000, "lines per goroutine")
+	flag.IntVar(&lineBytes, "line-bytes", 100, "bytes per line, newline included (at least 32)")
+	dir := flag.String("dir", ".", "directory for the log files")
+	flag.Parse()
+
+	lineBytes = max(lineBytes, 32)
+	path := filepath.Join(*dir, "concurrent_writers.tmp")
+	defer os.Remove(path)
+
+	fmt.Println("📜 MANY GHOSTS, ONE DIARY 📜")
+	fmt.Println(strings.Repeat("🩸", 25))
+	fmt.Printf("\n%d writers x %d lines of %dB into %s, mean of 3 runs\n\n", *writers, *lines, lineBytes, path)
+	fmt.Printf("%-32s %12s %12s %9s  %s\n", "strategy", "mean time", "lines/sec", "MB/s", "verdict")
+
+	for _, s := range logStrategies {
+		var total time.Duration
+		var verdict string
+		for run := 0; run < 3; run++ {
+			start := time.Now()
+			if err := s.run(path, *writers, *lines); err != nil {
+				panic(s.name + " failed: " + err.Error())
+			}
+			total += time.Since(start)
+			verdict = verifyLog(path, *writers, *lines)
+		}
+		mean := total / 3
+		n := *writers * *lines
+		fmt.Printf("%-32s %12v %12.0f %9.1f  %s\n", s.name, mean.Round(time.Microsecond),
+			float64(n)/mean.Seconds(), float64(n*lineBytes)/(1<<20)/mean.Seconds(), verdict)
+	}
+
+	fmt.Println("\n💀 The mutex and the funnel keep one buffer, so the lines come out whole but")
+	fmt.Println("every writer queues for it. O_APPEND lets the kernel pick each batch's offset,")
+	fmt.Println("and separate files never touch at all until the merge - like Wu Zetian's pilots")
+	fmt.Println("each keeping their own battle log and handing them in after the war.")
+}
+
+// logLine formats one line: writer and sequence number up front, padded
+// with ghosts to exactly lineBytes
+func logLine(dst []byte, writer, seq int) []byte {
+	start := len(dst)
+	dst = fmt.Appendf(dst, "writer=%05d seq=%08d ", writer, seq)
+	for len(dst)-start < lineBytes-1 {
+		dst = append(dst, '~')
+	}
+	return append(dst, '\n')
+}
+
+// mutexWriter shares one bufio.Writer and takes turns with a mutex
+func mutexWriter(path string, writers, lines int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var mu sync.Mutex
+	shared := bufio.NewWriter(file)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			var line []byte
+			for i := 0; i < lines; i++ {
+				line = logLine(line[:0], id, i)
+				mu.Lock()
+				shared.Write(line)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	return shared.Flush()
+}
+
+// channelFunnel sends every line to the only goroutine allowed to write
+func channelFunnel(path string, writers, lines int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	funnel := make(chan []byte, 1024)
+	done := make(chan error)
+	go func() {
+		out := bufio.NewWriter(file)
+		for line := range funnel {
+			out.Write(line)
+		}
+		done <- out.Flush()
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				funnel <- logLine(nil, id, i) // A fresh slice - the writer owns it now
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(funnel)
+	return <-done
+}
+
+// appendBatches gives every goroutine its own fd opened with O_APPEND and
+// its own 4KB of whole lines. Each write(2) lands at the end of the file in
+// one piece, so batches never splice into each other.
+func appendBatches(path string, writers, lines int) error {
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		return err
+	}
+
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer file.Close()
+
+			batch := make([]byte, 0, max(4096, lineBytes))
+			for i := 0; i < lines; i++ {
+				if len(batch)+lineBytes > cap(batch) {
+					if _, err := file.Write(batch); err != nil {
+						errs <- err
+						return
+					}
+					batch = batch[:0]
+				}
+				batch = logLine(batch, id, i)
+			}
+			if _, err := file.Write(batch); err != nil {
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs // nil once closed and empty
+}
+
+// filePerWriter never shares anything, then concatenates the pieces
+func filePerWriter(path string, writers, lines int) error {
+	parts := make([]string, writers)
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		parts[w] = fmt.Sprintf("%s.%d", path, w)
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			file, err := os.Create(parts[id])
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer file.Close()
+
+			out := bufio.NewWriter(file)
+			var line []byte
+			for i := 0; i < lines; i++ {
+				line = logLine(line[:0], id, i)
+				out.Write(line)
+			}
+			if err := out.Flush(); err != nil {
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	// The merge is part of the price
+	merged, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer merged.Close()
+	for _, part := range parts {
+		f, err := os.Open(part)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(merged, f)
+		f.Close()
+		os.Remove(part)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyLog reads the log back and checks that every line is whole, that
+// every writer's lines are all there, and that each writer's lines kept
+// their order
+func verifyLog(path string, writers, lines int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "❌ unreadable: " + err.Error()
+	}
+
+	next := make([]int, writers) // The seq we expect next from each writer
+	var total, torn, reordered int
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			end = len(data) - 1 // A last line with no newline is torn by definition
+		}
+		line := data[:end+1]
+		data = data[end+1:]
+		total++
+
+		id, seq, ok := parseLogLine(line)
+		if !ok || id >= writers {
+			torn++
+			continue
+		}
+		if seq != next[id] {
+			reordered++
+		}
+		next[id] = seq + 1
+	}
+
+	missing := 0
+	for _, n := range next {
+		missing += lines - n
+	}
+	if torn == 0 && reordered == 0 && missing == 0 && total == writers*lines {
+		return fmt.Sprintf("✅ %d lines, none torn, every writer in order", total)
+	}
+	return fmt.Sprintf("❌ %d lines: %d torn, %d out of order, %d missing", total, torn, reordered, missing)
+}
+
+// parseLogLine is logLine in reverse; anything else is a torn line
+func parseLogLine(line []byte) (writer, seq int, ok bool) {
+	const prefix = "writer=00000 seq=00000000 "
+	if len(line) != lineBytes || line[len(line)-1] != '\n' ||
+		!bytes.HasPrefix(line, []byte("writer=")) || !bytes.Equal(line[12:17], []byte(" seq=")) {
+		return 0, 0, false
+	}
+	writer, err1 := strconv.Atoi(string(line[7:12]))
+	seq, err2 := strconv.Atoi(string(line[17:25]))
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	for _, b := range line[len(prefix) : len(line)-1] {
+		if b != '~' {
+			return 0, 0, false
+		}
+	}
+	return writer, seq, true
+}
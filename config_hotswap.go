@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the grimoire every reader consults. It is never mutated after
+// publishing - a new version is a new struct.
+type Config struct {
+	Version     uint64
+	PublishedAt time.Time
+	RateLimit   int
+	Endpoints   []string
+}
+
+// configSource publishes configs and hands each reader its own way to read them
+type configSource interface {
+	Publish(c *Config)
+	Reader() func() *Config
+}
+
+// atomicSource swaps a pointer - readers never block
+type atomicSource struct {
+	p atomic.Pointer[Config]
+}
+
+func (s *atomicSource) Publish(c *Config) { s.p.Store(c) }
+
+func (s *atomicSource) Reader() func() *Config { return s.p.Load }
+
+// rwMutexSource guards the pointer with a read lock
+type rwMutexSource struct {
+	mu  sync.RWMutex
+	cfg *Config
+}
+
+func (s *rwMutexSource) Publish(c *Config) {
+	s.mu.Lock()
+	s.cfg = c
+	s.mu.Unlock()
+}
+
+func (s *rwMutexSource) Reader() func() *Config {
+	return func() *Config {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.cfg
+	}
+}
+
+// broadcastSource sends every version down a per-reader channel; readers
+// keep their last copy and only check the mailbox without blocking
+type broadcastSource struct {
+	mu    sync.Mutex
+	cfg   *Config
+	boxes []chan *Config
+}
+
+func (s *broadcastSource) Publish(c *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = c
+	for _, box := range s.boxes {
+		// Replace whatever stale version is still waiting in the mailbox
+		select {
+		case <-box:
+		default:
+		}
+		box <- c
+	}
+}
+
+func (s *broadcastSource) Reader() func() *Config {
+	s.mu.Lock()
+	box := make(chan *Config, 1)
+	s.boxes = append(s.boxes, box)
+	cur := s.cfg
+	s.mu.Unlock()
+
+	return func() *Config {
+		select {
+		case c := <-box:
+			cur = c
+		default:
+		}
+		return cur
+	}
+}
+
+type hotswapResult struct {
+	reads     uint64
+	elapsed   time.Duration
+	staleness []time.Duration // Publish-to-last-reader delay for each version
+}
+
+func main() {
+	fmt.Println("🔮 CONFIG HOT-SWAP SÉANCE 🔮")
+	fmt.Println(strings.Repeat("⚡", 30))
+
+	const readers = 50
+	const duration = time.Second
+	const publishEvery = 10 * time.Millisecond
+
+	fmt.Printf("\n%d readers, a new config every %v for %v, GOMAXPROCS=%d\n",
+		readers, publishEvery, duration, runtime.GOMAXPROCS(0))
+
+	sources := []struct {
+		name string
+		make func() configSource
+	}{
+		{"atomic.Pointer", func() configSource { return &atomicSource{} }},
+		{"RWMutex", func() configSource { return &rwMutexSource{} }},
+		{"channel broadcast", func() configSource { return &broadcastSource{} }},
+	}
+
+	fmt.Printf("\n%-18s %14s %10s %12s %12s %12s\n",
+		"source", "reads/sec", "versions", "stale p50", "stale p99", "stale max")
+	for _, s := range sources {
+		r := runHotswap(s.make(), readers, duration, publishEvery)
+		slices.Sort(r.staleness)
+		fmt.Printf("%-18s %14.0f %10d %12v %12v %12v\n", s.name,
+			float64(r.reads)/r.elapsed.Seconds(), len(r.staleness),
+			stalePercentile(r.staleness, 0.50),
+			stalePercentile(r.staleness, 0.99),
+			stalePercentile(r.staleness, 1.0))
+	}
+
+	fmt.Println("\n💀 Staleness = time from Publish until the LAST of the readers sees that version (or newer).")
+	fmt.Println("atomic.Pointer readers never wait - like Wu Zetian swapping pilots mid-battle")
+	fmt.Println("while the mech keeps walking.")
+}
+
+// runHotswap lets readers consume configs while a publisher keeps swapping them
+func runHotswap(src configSource, readers int, duration, publishEvery time.Duration) hotswapResult {
+	src.Publish(&Config{Version: 0, PublishedAt: time.Now(), RateLimit: 100})
+
+	maxVersions := int(duration/publishEvery) + 1
+	// publishedAt is written before each Publish, so any reader that sees
+	// version v is guaranteed to see publishedAt[v] too
+	publishedAt := make([]time.Time, maxVersions+1)
+	seenBy := make([]atomic.Int32, maxVersions+1)
+	stale := make([]atomic.Int64, maxVersions+1)
+
+	var stop atomic.Bool
+	var totalReads atomic.Uint64
+	var wg sync.WaitGroup
+
+	for r := 0; r < readers; r++ {
+		read := src.Reader()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var lastSeen uint64
+			var reads uint64
+			sum := 0
+			for !stop.Load() {
+				cfg := read()
+				sum += cfg.RateLimit + len(cfg.Endpoints) // Actually consult the grimoire
+				reads++
+
+				// Seeing version v also counts as having seen everything before it
+				for v := lastSeen + 1; v <= cfg.Version; v++ {
+					if seenBy[v].Add(1) == int32(readers) {
+						stale[v].Store(int64(time.Since(publishedAt[v])))
+					}
+				}
+				lastSeen = max(lastSeen, cfg.Version)
+
+				// A request boundary - let the publisher in even when Ps are scarce
+				if reads%128 == 0 {
+					runtime.Gosched()
+				}
+			}
+			_ = sum
+			totalReads.Add(reads)
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(publishEvery)
+	for v := 1; v <= maxVersions && time.Since(start) < duration; v++ {
+		<-ticker.C
+		publishedAt[v] = time.Now()
+		src.Publish(&Config{
+			Version:     uint64(v),
+			PublishedAt: publishedAt[v],
+			RateLimit:   100 + v,
+			Endpoints:   []string{"ethel", "cain"},
+		})
+	}
+	ticker.Stop()
+	time.Sleep(publishEvery) // Give stragglers a chance to see the final version
+	stop.Store(true)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var result hotswapResult
+	result.reads = totalReads.Load()
+	result.elapsed = elapsed
+	for v := 1; v <= maxVersions; v++ {
+		if seenBy[v].Load() == int32(readers) {
+			result.staleness = append(result.staleness, time.Duration(stale[v].Load()))
+		}
+	}
+	return result
+}
+
+func stalePercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
@@ -1,14 +1,51 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"os/exec"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+// measuredSwitches holds the per-switch costs that fill in the hierarchy table
+type measuredSwitches struct {
+	thread time.Duration // LockOSThread goroutines over threadWakeup
+	pipe   time.Duration // Two processes over a pair of pipes
+	socket time.Duration // Two processes over a Unix socketpair
+
+	singleSched *SchedStats // Last attempt of experiment 1
+	multiSched  *SchedStats // Last attempt of experiment 2
+}
+
 func main() {
+	child := flag.Bool("child", false, "internal: echo every byte from fd 3 back on fd 4 for the process ping-pong")
+	pin := flag.String("pin", "all", "pinned placements to measure: same-cpu, smt, same-l3, cross-l3, all or none (comma-separated)")
+	quota := flag.String("quota", "", "rerun the GOMAXPROCS sweep in a child limited to this many CPUs (comma-separated), like a container CPU limit")
+	sweepOnly := flag.Int("sweep-only", 0, "internal: run only the GOMAXPROCS sweep, from 1 up to this many Ps")
+	flag.Parse()
+
+	if *child {
+		runEchoChild()
+		return
+	}
+
+	// Every experiment below bends GOMAXPROCS - put it back when we're done
+	original := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(original)
+
+	if *sweepOnly > 0 {
+		runProcsSweep(*sweepOnly)
+		return
+	}
+
 	fmt.Println("👻 CONTEXT SWITCHING SÉANCE 👻")
 	fmt.Println(strings.Repeat("⚡", 30))
 
@@ -16,14 +53,16 @@ func main() {
 
 	// Run each experiment 3 times for averages
 	var singleThreadTimes, multiThreadTimes []time.Duration
+	var singleSched, multiSched *SchedStats
 
 	fmt.Println("\n🕯️ EXPERIMENT 1: SINGLE THREAD POSSESSION 🕯️")
 	fmt.Println("(All ghosts must share ONE body)")
 
 	for i := 0; i < 3; i++ {
 		runtime.GOMAXPROCS(1) // Force single-thread haunting
-		duration := runPingPong(pingPongs, i+1)
+		duration, sched := runPingPong(pingPongs, i+1)
 		singleThreadTimes = append(singleThreadTimes, duration)
+		singleSched = sched
 	}
 
 	fmt.Println("\n💀 EXPERIMENT 2: MULTI-THREAD CHAOS 💀")
@@ -31,15 +70,69 @@ func main() {
 
 	for i := 0; i < 3; i++ {
 		runtime.GOMAXPROCS(runtime.NumCPU()) // Unleash all cores!
-		duration := runPingPong(pingPongs, i+1)
+		duration, sched := runPingPong(pingPongs, i+1)
 		multiThreadTimes = append(multiThreadTimes, duration)
+		multiSched = sched
+	}
+	runtime.GOMAXPROCS(original)
+
+	const osPingPongs = 100_000 // Every one of these goes through the kernel
+
+	fmt.Println("\n🧵 EXPERIMENT 3: OS THREAD POSSESSION 🧵")
+	fmt.Printf("(Two LockOSThread goroutines waking each other through %s)\n", threadWakeup)
+
+	var threadTimes []time.Duration
+	for i := 0; i < 3; i++ {
+		// A thread blocked in read keeps its P, so the partner needs its own
+		runtime.GOMAXPROCS(max(2, runtime.NumCPU()))
+		threadTimes = append(threadTimes, runThreadPingPong(osPingPongs, i+1))
+	}
+	runtime.GOMAXPROCS(original)
+
+	fmt.Println("\n🏚️ EXPERIMENT 4: PROCESS POSSESSION 🏚️")
+	fmt.Println("(This program and a child copy of itself passing one byte back and forth)")
+
+	var pipeTimes, socketTimes []time.Duration
+	for i := 0; i < 3; i++ {
+		pipeTimes = append(pipeTimes, runProcessPingPong(osPingPongs, i+1, "pipes", pipeLink))
+	}
+	for i := 0; i < 3; i++ {
+		socketTimes = append(socketTimes, runProcessPingPong(osPingPongs, i+1, "Unix socket", socketLink))
+	}
+
+	if *pin != "none" {
+		fmt.Println("\n📌 EXPERIMENT 5: PINNED POSSESSION 📌")
+		fmt.Println("(Both spirits locked to OS threads and nailed to chosen CPUs)")
+		runPlacements(osPingPongs, *pin)
+	}
+
+	fmt.Println("\n🎭 EXPERIMENT 6: OTHER WAYS TO PASS A SOUL 🎭")
+	fmt.Println("(The same round trip through every handoff primitive we own)")
+	runHandoffPrimitives(pingPongs / 5)
+
+	fmt.Println("\n📈 EXPERIMENT 7: HOW MANY BODIES? 📈")
+	fmt.Println("(Ping-pong at every GOMAXPROCS from 1 to NumCPU)")
+	runProcsSweep(runtime.NumCPU())
+	if *quota != "" {
+		runQuotaSweeps(*quota)
+	}
+
+	perSwitch := func(times []time.Duration) time.Duration {
+		return average(times) / time.Duration(osPingPongs*2)
 	}
 
 	// Calculate and display the cursed results
-	displayResults(singleThreadTimes, multiThreadTimes, pingPongs)
+	displayResults(singleThreadTimes, multiThreadTimes, pingPongs, measuredSwitches{
+		thread: perSwitch(threadTimes),
+		pipe:   perSwitch(pipeTimes),
+		socket: perSwitch(socketTimes),
+
+		singleSched: singleSched,
+		multiSched:  multiSched,
+	})
 }
 
-func runPingPong(rounds int, attempt int) time.Duration {
+func runPingPong(rounds int, attempt int) (time.Duration, *SchedStats) {
 	fmt.Printf("\n🔮 Attempt %d: Summoning goroutines...\n", attempt)
 
 	// The haunted channel - unbuffered for immediate possession transfer
@@ -49,6 +142,7 @@ func runPingPong(rounds int, attempt int) time.Duration {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// Goroutine 1: The Sexyy Red Spirit
@@ -71,13 +165,365 @@ func runPingPong(rounds int, attempt int) time.Duration {
 
 	wg.Wait()
 	duration := time.Since(startTime)
+	sched := probe.Stop()
 
 	fmt.Printf("✨ Completed %d ping-pongs in %v\n", rounds, duration)
+	fmt.Println(sched.Summary())
+
+	return duration, sched
+}
+
+// handoff is one way for two goroutines to take turns: run does rounds
+// round trips and returns how long they took
+type handoff struct {
+	name string
+	run  func(rounds int) time.Duration
+}
+
+var handoffs = []handoff{
+	{"unbuffered chan", func(rounds int) time.Duration {
+		return chanHandoff(rounds, make(chan struct{}), make(chan struct{}))
+	}},
+	{"buffered chan (1)", func(rounds int) time.Duration {
+		return chanHandoff(rounds, make(chan struct{}, 1), make(chan struct{}, 1))
+	}},
+	{"sync.Mutex handoff", mutexHandoff},
+	{"sync.Cond signal", condHandoff},
+	{"atomic spin+Gosched", spinHandoff},
+	{"select over 4 chans", selectHandoff},
+}
+
+// runHandoffPrimitives times every handoff at GOMAXPROCS=1 and NumCPU,
+// then puts GOMAXPROCS back the way it found it
+func runHandoffPrimitives(rounds int) {
+	original := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(original)
+
+	fmt.Printf("\n%d round trips each, 2 switches per round trip\n\n", rounds)
+	fmt.Printf("%-20s %16s %16s\n", "primitive", "P=1 per switch", fmt.Sprintf("P=%d per switch", runtime.NumCPU()))
+	for _, h := range handoffs {
+		runtime.GOMAXPROCS(1)
+		single := h.run(rounds) / time.Duration(rounds*2)
+		runtime.GOMAXPROCS(runtime.NumCPU())
+		multi := h.run(rounds) / time.Duration(rounds*2)
+		fmt.Printf("%-20s %16v %16v\n", h.name, single, multi)
+	}
+}
+
+// runPair starts a and b together and waits for both
+func runPair(a, b func()) time.Duration {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	startTime := time.Now()
+	go func() {
+		defer wg.Done()
+		a()
+	}()
+	go func() {
+		defer wg.Done()
+		b()
+	}()
+	wg.Wait()
+	return time.Since(startTime)
+}
+
+func chanHandoff(rounds int, ping, pong chan struct{}) time.Duration {
+	return runPair(func() {
+		for i := 0; i < rounds; i++ {
+			ping <- struct{}{}
+			<-pong
+		}
+	}, func() {
+		for i := 0; i < rounds; i++ {
+			<-ping
+			pong <- struct{}{}
+		}
+	})
+}
+
+// mutexHandoff uses two locked mutexes as batons: unlocking one is the
+// signal, locking the other is the wait
+func mutexHandoff(rounds int) time.Duration {
+	var ping, pong sync.Mutex
+	ping.Lock()
+	pong.Lock()
+	return runPair(func() {
+		for i := 0; i < rounds; i++ {
+			ping.Unlock()
+			pong.Lock()
+		}
+	}, func() {
+		for i := 0; i < rounds; i++ {
+			ping.Lock()
+			pong.Unlock()
+		}
+	})
+}
+
+// condHandoff flips whose turn it is under a mutex and signals the other side
+func condHandoff(rounds int) time.Duration {
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	turn := 0
+	take := func(mine, next int) {
+		mu.Lock()
+		for turn != mine {
+			cond.Wait()
+		}
+		turn = next
+		cond.Signal()
+		mu.Unlock()
+	}
+	return runPair(func() {
+		for i := 0; i < rounds; i++ {
+			take(0, 1)
+		}
+	}, func() {
+		for i := 0; i < rounds; i++ {
+			take(1, 0)
+		}
+	})
+}
+
+// spinHandoff never sleeps: each side polls an atomic and yields its P
+// between polls
+func spinHandoff(rounds int) time.Duration {
+	var turn atomic.Int32
+	take := func(mine, next int32) {
+		for turn.Load() != mine {
+			runtime.Gosched()
+		}
+		turn.Store(next)
+	}
+	return runPair(func() {
+		for i := 0; i < rounds; i++ {
+			take(0, 1)
+		}
+	}, func() {
+		for i := 0; i < rounds; i++ {
+			take(1, 0)
+		}
+	})
+}
+
+// selectHandoff sends each round on a different channel so the receiver
+// has to select across all four
+func selectHandoff(rounds int) time.Duration {
+	var pings, pongs [4]chan struct{}
+	for i := range pings {
+		pings[i] = make(chan struct{})
+		pongs[i] = make(chan struct{})
+	}
+	receive := func(chs *[4]chan struct{}) {
+		select {
+		case <-chs[0]:
+		case <-chs[1]:
+		case <-chs[2]:
+		case <-chs[3]:
+		}
+	}
+	return runPair(func() {
+		for i := 0; i < rounds; i++ {
+			pings[i%4] <- struct{}{}
+			receive(&pongs)
+		}
+	}, func() {
+		for i := 0; i < rounds; i++ {
+			receive(&pings)
+			pongs[i%4] <- struct{}{}
+		}
+	})
+}
+
+// runThreadPingPong is runPingPong with real OS threads: each spirit is
+// locked to its own thread and sleeps in the kernel until poked
+func runThreadPingPong(rounds int, attempt int) time.Duration {
+	fmt.Printf("\n🔮 Attempt %d: Binding spirits to OS threads...\n", attempt)
+
+	probe := StartSchedProbe()
+	duration := wakerPingPong(rounds, unpinned)
+	sched := probe.Stop()
+
+	fmt.Printf("✨ Completed %d thread ping-pongs in %v\n", rounds, duration)
+	fmt.Println(sched.Summary())
+
+	return duration
+}
+
+// wakerPingPong bounces a wake-up between two locked threads through a
+// pair of threadWakers - eventfds on Linux, pipes elsewhere
+func wakerPingPong(rounds int, cpus [2]int) time.Duration {
+	ping, pong := newThreadWaker(), newThreadWaker()
+	defer ping.close()
+	defer pong.close()
+
+	return runLockedPair(cpus, func() {
+		for i := 0; i < rounds; i++ {
+			ping.signal()
+			pong.wait()
+		}
+	}, func() {
+		for i := 0; i < rounds; i++ {
+			ping.wait()
+			pong.signal()
+		}
+	})
+}
+
+// channelPingPong is runPingPong's ritual, but with both goroutines locked
+// to OS threads so they can be pinned
+func channelPingPong(rounds int, cpus [2]int) time.Duration {
+	ping := make(chan struct{})
+	pong := make(chan struct{})
+
+	return runLockedPair(cpus, func() {
+		for i := 0; i < rounds; i++ {
+			ping <- struct{}{}
+			<-pong
+		}
+	}, func() {
+		for i := 0; i < rounds; i++ {
+			<-ping
+			pong <- struct{}{}
+		}
+	})
+}
+
+// unpinned leaves both threads wherever the kernel likes
+var unpinned = [2]int{-1, -1}
+
+// runLockedPair runs a and b on their own OS threads, pinned to cpus
+// (-1 = anywhere), and times them from the moment both are in place.
+// Pinned threads exit still locked, so the runtime throws them away
+// instead of reusing a thread with a strange affinity mask.
+func runLockedPair(cpus [2]int, a, b func()) time.Duration {
+	var ready, done sync.WaitGroup
+	ready.Add(2)
+	done.Add(2)
+	start := make(chan struct{})
+
+	for i, body := range []func(){a, b} {
+		go func() {
+			defer done.Done()
+			runtime.LockOSThread()
+			if cpus[i] >= 0 {
+				if err := setAffinity(cpus[i]); err != nil {
+					panic(fmt.Sprintf("Could not bind to CPU %d: %v", cpus[i], err))
+				}
+			} else {
+				defer runtime.UnlockOSThread()
+			}
+			ready.Done()
+			<-start
+			body()
+		}()
+	}
+
+	ready.Wait()
+	startTime := time.Now()
+	close(start)
+	done.Wait()
+	return time.Since(startTime)
+}
+
+// readRetry and writeRetry are plain blocking syscalls that shrug off the
+// runtime's preemption signals
+func readRetry(fd int, buf []byte) (int, error) {
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err != syscall.EINTR {
+			return n, err
+		}
+	}
+}
+
+func writeRetry(fd int, buf []byte) (int, error) {
+	for {
+		n, err := syscall.Write(fd, buf)
+		if err != syscall.EINTR {
+			return n, err
+		}
+	}
+}
+
+// A processLink opens the parent's read and write fds plus the files the
+// child inherits as fd 3 (read) and fd 4 (write). All fds are blocking, so
+// Go's netpoller stays out of the measurement.
+type processLink func() (parentR, parentW int, child []*os.File, err error)
+
+// runProcessPingPong bounces one byte off a child copy of this program
+func runProcessPingPong(rounds int, attempt int, name string, link processLink) time.Duration {
+	fmt.Printf("\n🔮 Attempt %d: Possessing a second process over %s...\n", attempt, name)
+
+	r, w, childFiles, err := link()
+	if err != nil {
+		panic("Could not open " + name + ": " + err.Error())
+	}
+	self, err := os.Executable()
+	if err != nil {
+		panic("Lost our own body: " + err.Error())
+	}
+
+	cmd := exec.Command(self, "-child")
+	cmd.ExtraFiles = childFiles
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		panic("Child process refused to rise: " + err.Error())
+	}
+	for _, f := range childFiles {
+		f.Close() // The child has its copies now
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	soul := []byte{0}
+	bounce := func() {
+		if _, err := writeRetry(w, soul); err != nil {
+			panic("Parent write failed: " + err.Error())
+		}
+		if n, err := readRetry(r, soul); n != 1 || err != nil {
+			panic(fmt.Sprintf("Child stopped answering: n=%d err=%v", n, err))
+		}
+	}
+
+	bounce() // Keep exec and runtime start-up off the clock
+
+	startTime := time.Now()
+	for i := 0; i < rounds; i++ {
+		bounce()
+	}
+	duration := time.Since(startTime)
+
+	// EOF tells the child to go back to the grave
+	syscall.Close(w)
+	if r != w {
+		syscall.Close(r)
+	}
+	if err := cmd.Wait(); err != nil {
+		panic("Child process died badly: " + err.Error())
+	}
+
+	fmt.Printf("✨ Completed %d process ping-pongs in %v\n", rounds, duration)
 
 	return duration
 }
 
-func displayResults(singleThread, multiThread []time.Duration, rounds int) {
+// runEchoChild is the other process: read a byte, send it straight back
+func runEchoChild() {
+	soul := make([]byte, 1)
+	for {
+		n, err := readRetry(3, soul)
+		if n == 0 || err != nil {
+			return
+		}
+		if _, err := writeRetry(4, soul); err != nil {
+			return
+		}
+	}
+}
+
+func displayResults(singleThread, multiThread []time.Duration, rounds int, measured measuredSwitches) {
 	fmt.Println("\n" + strings.Repeat("🩸", 30))
 	fmt.Println("\n⚰️ THE CONTEXT SWITCHING AUTOPSY ⚰️")
 
@@ -101,6 +547,12 @@ func displayResults(singleThread, multiThread []time.Duration, rounds int) {
 		fmt.Printf("\n🎭 Multi-thread is %.2fx faster!\n", ratio)
 	}
 
+	fmt.Println("\n📊 WHAT THE SCHEDULER SAW (last attempt of each):")
+	fmt.Println("Single-thread " + measured.singleSched.Summary())
+	fmt.Print(measured.singleSched.Histogram())
+	fmt.Println("Multi-thread " + measured.multiSched.Summary())
+	fmt.Print(measured.multiSched.Histogram())
+
 	fmt.Print(`
 
 ╔════════════════════════════════════════════════════════════╗
@@ -118,22 +570,29 @@ func displayResults(singleThread, multiThread []time.Duration, rounds int) {
 ║ • Must sync through OS kernel sometimes                   ║
 ║ • Like ghosts jumping between different houses           ║
 ║ • Cache invalidation, memory barriers, tears              ║
-║                                                            ║
+`)
+	fmt.Printf("║ • Measured runnable delay p99: ≤%-8s (single ≤%-8s) ║\n",
+		shortDuration(measured.multiSched.DelayPercentile(0.99)),
+		shortDuration(measured.singleSched.DelayPercentile(0.99)))
+	fmt.Print(`║                                                            ║
 ║ THE WITCH'S HIERARCHY OF SWITCHING HORROR:                ║
+║ (measured on this machine, the old guesses in brackets)   ║
 ║                                                            ║
-║ 🌟 Goroutine switch (same thread): ~100ns                 ║
-║    "Like Ayesha switching personalities"                  ║
-║                                                            ║
-║ 💀 Thread switch (same process): ~1-10μs                  ║
-║    "Like Eve Brown switching between tasks"              ║
-║                                                            ║
-║ 🩸 Process switch: ~10-100μs                              ║
-║    "Like Wu Zetian switching between pilots"             ║
+`)
+	fmt.Printf("║ 🌟 Goroutine switch (same thread): %-10v [~100ns]    ║\n", switchTimeSingle)
+	fmt.Println(`║    "Like Ayesha switching personalities"                  ║
+║                                                            ║`)
+	fmt.Printf("║ 💀 Thread switch (%s): %-*v [~1-10μs]         ║\n", threadWakeup, 17-len(threadWakeup), measured.thread)
+	fmt.Println(`║    "Like Eve Brown switching between tasks"              ║
+║                                                            ║`)
+	fmt.Printf("║ 🩸 Process switch (pipes): %-10v [~10-100μs]         ║\n", measured.pipe)
+	fmt.Printf("║ 🩸 Process switch (Unix socket): %-10v              ║\n", measured.socket)
+	fmt.Print(`║    "Like Wu Zetian switching between pilots"             ║
 ║                                                            ║
-║ ⚰️ Container switch: ~100μs-1ms                           ║
+║ ⚰️ Container switch: ~100μs-1ms (not measured)             ║
 ║    "Like switching between Monaleo verses"               ║
 ║                                                            ║
-║ 👹 VM switch: ~1-100ms                                    ║
+║ 👹 VM switch: ~1-100ms (not measured)                      ║
 ║    "Like switching between Chappell Roan eras"           ║
 ║                                                            ║
 ╚════════════════════════════════════════════════════════════╝
@@ -155,3 +614,242 @@ func average(durations []time.Duration) time.Duration {
 	}
 	return total / time.Duration(len(durations))
 }
+
+// runProcsSweep times the channel ping-pong at each GOMAXPROCS up to
+// maxProcs and draws latency against the number of Ps
+func runProcsSweep(maxProcs int) {
+	const rounds = 200_000
+
+	original := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(original)
+
+	var procs []int
+	for p := 1; p <= maxProcs; p++ {
+		// Past 16 Ps only the powers of two are interesting
+		if p <= 16 || p&(p-1) == 0 || p == maxProcs {
+			procs = append(procs, p)
+		}
+	}
+
+	type sweepPoint struct {
+		procs     int
+		perSwitch time.Duration
+		delayP99  time.Duration
+	}
+	var points []sweepPoint
+	var slowest time.Duration
+	for _, p := range procs {
+		runtime.GOMAXPROCS(p)
+		probe := StartSchedProbe()
+		d := chanHandoff(rounds, make(chan struct{}), make(chan struct{}))
+		sched := probe.Stop()
+
+		pt := sweepPoint{p, d / time.Duration(rounds*2), sched.DelayPercentile(0.99)}
+		points = append(points, pt)
+		slowest = max(slowest, pt.perSwitch)
+	}
+
+	fmt.Printf("\nCPUs this process may run on: %d, %d round trips per point\n\n", len(allowedCPUs()), rounds)
+	fmt.Printf("%-4s %12s %11s\n", "Ps", "per switch", "delay p99")
+	for _, pt := range points {
+		width := int(40 * pt.perSwitch / max(slowest, 1))
+		fmt.Printf("%-4d %12v %11s %s\n", pt.procs, pt.perSwitch,
+			"≤"+shortDuration(pt.delayP99), strings.Repeat("█", max(width, 1)))
+	}
+}
+
+// runQuotaSweeps reruns the sweep in a child that may only use the first
+// few CPUs, the way a container with a CPU limit sees a big machine. The
+// child inherits the affinity of the thread that forks it, and its Ps
+// still go all the way up to our NumCPU.
+func runQuotaSweeps(quota string) {
+	self, err := os.Executable()
+	if err != nil {
+		panic("Lost our own body: " + err.Error())
+	}
+	if !canPin {
+		fmt.Println("\n⚠️ Skipping the quota sweeps - binding a child to CPUs needs sched_setaffinity (Linux)")
+		return
+	}
+	allowed := allowedCPUs()
+
+	for _, field := range strings.Split(quota, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 || n > len(allowed) {
+			fmt.Printf("\n⚠️ Skipping quota %q - we may only use %d CPUs\n", field, len(allowed))
+			continue
+		}
+		fmt.Printf("\n🪦 Quota of %d CPU(s): a child bound to %v, GOMAXPROCS up to %d\n", n, allowed[:n], runtime.NumCPU())
+
+		done := make(chan error)
+		go func() {
+			// Never unlocked - the restricted thread dies with this goroutine
+			runtime.LockOSThread()
+			if err := setAffinity(allowed[:n]...); err != nil {
+				done <- err
+				return
+			}
+			cmd := exec.Command(self, "-sweep-only", strconv.Itoa(runtime.NumCPU()))
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			done <- cmd.Run()
+		}()
+		if err := <-done; err != nil {
+			panic("Quota child failed: " + err.Error())
+		}
+	}
+}
+
+// placement nails the two ping-pong participants to specific CPUs
+type placement struct {
+	name string
+	cpus [2]int
+}
+
+// cpuInfo is what sysfs says about one logical CPU
+type cpuInfo struct {
+	siblings []int // Hyperthreads sharing this physical core (including itself)
+	l3       []int // CPUs sharing this one's last-level cache
+	pkg      int   // Physical socket
+}
+
+// runPlacements measures channel and threadWaker ping-pong for every
+// requested placement this machine can actually offer
+func runPlacements(rounds int, which string) {
+	original := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(original)
+
+	topo, allowed := readTopology(), allowedCPUs()
+	fmt.Printf("\nCPUs we may run on: %v\n", allowed)
+
+	wanted := strings.Split(which, ",")
+	if !canPin {
+		fmt.Println("(No thread affinity on this OS - only the unpinned pair runs)")
+	}
+	fmt.Printf("\n%-10s %-8s %16s %16s\n", "placement", "cpus", "chan per switch", threadWakeup+"/switch")
+	for _, name := range []string{"unpinned", "same-cpu", "smt", "same-l3", "cross-l3"} {
+		if name != "unpinned" && (!canPin || which != "all" && !slices.Contains(wanted, name)) {
+			continue
+		}
+		p, ok := findPlacement(name, topo, allowed)
+		if !ok {
+			fmt.Printf("%-10s skipped - this machine has no such pair of CPUs\n", name)
+			continue
+		}
+
+		runtime.GOMAXPROCS(max(2, runtime.NumCPU()))
+		ch := channelPingPong(rounds, p.cpus) / time.Duration(rounds*2)
+		ev := wakerPingPong(rounds, p.cpus) / time.Duration(rounds*2)
+
+		where := "-"
+		if p.cpus[0] >= 0 {
+			where = fmt.Sprintf("%d,%d", p.cpus[0], p.cpus[1])
+		}
+		fmt.Printf("%-10s %-8s %16v %16v\n", p.name, where, ch, ev)
+	}
+
+	fmt.Println("\n💀 same-cpu forces a full OS switch every hop; smt shares a core's caches;")
+	fmt.Println("cross-l3 drags every message across the interconnect - like Eve Brown")
+	fmt.Println("mailing her to-do list to the other side of town.")
+}
+
+// findPlacement picks the first pair of allowed CPUs matching name
+func findPlacement(name string, topo map[int]cpuInfo, allowed []int) (placement, bool) {
+	if name == "unpinned" {
+		return placement{name, unpinned}, true
+	}
+	for _, a := range allowed {
+		if name == "same-cpu" {
+			return placement{name, [2]int{a, a}}, true
+		}
+		for _, b := range allowed {
+			if a == b {
+				continue
+			}
+			info := topo[a]
+			smt := slices.Contains(info.siblings, b)
+			sharedL3 := slices.Contains(info.l3, b)
+			switch {
+			case name == "smt" && smt,
+				name == "same-l3" && sharedL3 && !smt,
+				name == "cross-l3" && !sharedL3:
+				return placement{name, [2]int{a, b}}, true
+			}
+		}
+	}
+	return placement{}, false
+}
+
+// readTopology reads thread siblings, the L3 domain and the socket for
+// every online CPU. Without an L3 entry the whole socket counts as one.
+func readTopology() map[int]cpuInfo {
+	topo := make(map[int]cpuInfo)
+	for _, cpu := range parseCPUList(readSysfs("/sys/devices/system/cpu/online")) {
+		dir := fmt.Sprintf("/sys/devices/system/cpu/cpu%d/", cpu)
+		info := cpuInfo{
+			siblings: parseCPUList(readSysfs(dir + "topology/thread_siblings_list")),
+			pkg:      atoiOr(readSysfs(dir+"topology/physical_package_id"), 0),
+		}
+		for i := 0; ; i++ {
+			level := readSysfs(fmt.Sprintf("%scache/index%d/level", dir, i))
+			if level == "" {
+				break
+			}
+			if level == "3" {
+				info.l3 = parseCPUList(readSysfs(fmt.Sprintf("%scache/index%d/shared_cpu_list", dir, i)))
+			}
+		}
+		if len(info.siblings) == 0 {
+			info.siblings = []int{cpu}
+		}
+		topo[cpu] = info
+	}
+
+	for cpu, info := range topo {
+		if info.l3 == nil {
+			for other, o := range topo {
+				if o.pkg == info.pkg {
+					info.l3 = append(info.l3, other)
+				}
+			}
+			topo[cpu] = info
+		}
+	}
+	return topo
+}
+
+func readSysfs(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func atoiOr(s string, fallback int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+// parseCPUList understands the kernel's "0-3,8,10-11" format
+func parseCPUList(list string) []int {
+	var cpus []int
+	for _, part := range strings.Split(list, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			continue
+		}
+		last := first
+		if isRange {
+			last = atoiOr(hi, first)
+		}
+		for c := first; c <= last; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The Linux half of context_switching.go: eventfd wake-ups, pipe2 and
+// SOCK_CLOEXEC links, and per-thread CPU affinity. List this file with go
+// run on Linux, context_switching_other.go anywhere else.
+
+// threadWakeup names what wakerPingPong sleeps on
+const threadWakeup = "eventfd"
+
+// canPin reports whether setAffinity can nail a thread to a CPU
+const canPin = true
+
+// threadWaker is one eventfd: signal adds 1 to its counter, wait blocks the
+// whole OS thread until the counter is non-zero
+type threadWaker struct {
+	fd int
+}
+
+func newThreadWaker() threadWaker {
+	fd, _, errno := syscall.RawSyscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC, 0)
+	if errno != 0 {
+		panic("eventfd refused to open: " + errno.Error())
+	}
+	return threadWaker{int(fd)}
+}
+
+func (w threadWaker) signal() {
+	var buf [8]byte
+	binary.NativeEndian.PutUint64(buf[:], 1)
+	if _, err := writeRetry(w.fd, buf[:]); err != nil {
+		panic("eventfd write failed: " + err.Error())
+	}
+}
+
+func (w threadWaker) wait() {
+	var buf [8]byte
+	if _, err := readRetry(w.fd, buf[:]); err != nil {
+		panic("eventfd read failed: " + err.Error())
+	}
+}
+
+func (w threadWaker) close() {
+	syscall.Close(w.fd)
+}
+
+func pipeLink() (int, int, []*os.File, error) {
+	var down, up [2]int
+	if err := syscall.Pipe2(down[:], syscall.O_CLOEXEC); err != nil {
+		return 0, 0, nil, err
+	}
+	if err := syscall.Pipe2(up[:], syscall.O_CLOEXEC); err != nil {
+		syscall.Close(down[0])
+		syscall.Close(down[1])
+		return 0, 0, nil, err
+	}
+	return up[0], down[1], []*os.File{os.NewFile(uintptr(down[0]), "down"), os.NewFile(uintptr(up[1]), "up")}, nil
+}
+
+func socketLink() (int, int, []*os.File, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	end := os.NewFile(uintptr(fds[1]), "socket")
+	return fds[0], fds[0], []*os.File{end, end}, nil
+}
+
+// cpuMask is a cpu_set_t big enough for 1024 CPUs
+type cpuMask [1024 / 64]uint64
+
+// allowedCPUs asks the kernel which CPUs this process may use - a
+// container or taskset may have taken some away
+func allowedCPUs() []int {
+	var mask cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		panic("sched_getaffinity failed: " + errno.Error())
+	}
+	var cpus []int
+	for c := 0; c < len(mask)*64; c++ {
+		if mask[c/64]&(1<<(c%64)) != 0 {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus
+}
+
+// setAffinity pins the calling OS thread (not the whole process) to cpus.
+// The goroutine must already hold runtime.LockOSThread.
+func setAffinity(cpus ...int) error {
+	var mask cpuMask
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << (cpu % 64)
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
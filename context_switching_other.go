@@ -0,0 +1,108 @@
+//go:build unix && !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// The portable half of context_switching.go for macOS and the BSDs: no
+// eventfd, pipe2, SOCK_CLOEXEC or sched_setaffinity, so thread wake-ups go
+// through a pipe and every pinned placement is skipped. List this file with
+// go run instead of context_switching_linux.go.
+
+// threadWakeup names what wakerPingPong sleeps on
+const threadWakeup = "pipe"
+
+// canPin reports whether setAffinity can nail a thread to a CPU
+const canPin = false
+
+// threadWaker is a pipe: signal writes a byte, wait blocks the whole OS
+// thread until one arrives
+type threadWaker struct {
+	r, w int
+}
+
+func newThreadWaker() threadWaker {
+	r, w, err := cloexecPipe()
+	if err != nil {
+		panic("pipe refused to open: " + err.Error())
+	}
+	return threadWaker{r, w}
+}
+
+func (w threadWaker) signal() {
+	if _, err := writeRetry(w.w, []byte{1}); err != nil {
+		panic("pipe write failed: " + err.Error())
+	}
+}
+
+func (w threadWaker) wait() {
+	var buf [1]byte
+	if _, err := readRetry(w.r, buf[:]); err != nil {
+		panic("pipe read failed: " + err.Error())
+	}
+}
+
+func (w threadWaker) close() {
+	syscall.Close(w.r)
+	syscall.Close(w.w)
+}
+
+// cloexecPipe makes a blocking pipe and marks both ends close-on-exec
+// under ForkLock, the way os/exec would without pipe2
+func cloexecPipe() (r, w int, err error) {
+	var fds [2]int
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	if err := syscall.Pipe(fds[:]); err != nil {
+		return 0, 0, err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	return fds[0], fds[1], nil
+}
+
+func pipeLink() (int, int, []*os.File, error) {
+	downR, downW, err := cloexecPipe()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	upR, upW, err := cloexecPipe()
+	if err != nil {
+		syscall.Close(downR)
+		syscall.Close(downW)
+		return 0, 0, nil, err
+	}
+	return upR, downW, []*os.File{os.NewFile(uintptr(downR), "down"), os.NewFile(uintptr(upW), "up")}, nil
+}
+
+func socketLink() (int, int, []*os.File, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	end := os.NewFile(uintptr(fds[1]), "socket")
+	return fds[0], fds[0], []*os.File{end, end}, nil
+}
+
+// allowedCPUs can't ask the kernel here, so it assumes every CPU
+func allowedCPUs() []int {
+	cpus := make([]int, runtime.NumCPU())
+	for i := range cpus {
+		cpus[i] = i
+	}
+	return cpus
+}
+
+// setAffinity has no per-thread equivalent outside Linux
+func setAffinity(cpus ...int) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LockObserver receives timings from a lock that is being watched.
+// *LockStats from lockstats.go is one.
+type LockObserver interface {
+	ObserveAcquire(wait time.Duration, contended bool)
+	ObserveRelease(hold time.Duration)
+}
+
+// CtxMutex is a mutex whose waiters can give up. The zero value is unlocked.
+// Lock/Unlock/TryLock behave like sync.Mutex, so it drops into any mu field.
+type CtxMutex struct {
+	once       sync.Once
+	sem        chan struct{} // Holding the lock = owning the single slot
+	observer   LockObserver
+	acquiredAt time.Time // Only touched by the holder, and only when observed
+}
+
+func (m *CtxMutex) slot() chan struct{} {
+	m.once.Do(func() { m.sem = make(chan struct{}, 1) })
+	return m.sem
+}
+
+// Observe reports every acquisition and release to o. Call it before the
+// mutex is shared.
+func (m *CtxMutex) Observe(o LockObserver) {
+	m.observer = o
+}
+
+// acquired runs right after the slot is taken
+func (m *CtxMutex) acquired(waitStart time.Time, contended bool) {
+	if m.observer != nil {
+		m.acquiredAt = time.Now()
+		var wait time.Duration
+		if contended {
+			wait = m.acquiredAt.Sub(waitStart)
+		}
+		m.observer.ObserveAcquire(wait, contended)
+	}
+	traceAcquired(m)
+}
+
+// Lock blocks until the mutex is free
+func (m *CtxMutex) Lock() {
+	m.LockContext(context.Background())
+}
+
+// TryLock takes the mutex only if it is free right now
+func (m *CtxMutex) TryLock() bool {
+	select {
+	case m.slot() <- struct{}{}:
+		m.acquired(time.Time{}, false)
+		return true
+	default:
+		return false
+	}
+}
+
+// LockContext waits for the mutex until ctx is done, returning ctx.Err()
+// if it gives up. A free mutex is always taken, even with ctx already done.
+func (m *CtxMutex) LockContext(ctx context.Context) error {
+	traceAcquiring(m)
+	if m.TryLock() {
+		return nil
+	}
+
+	var start time.Time
+	if m.observer != nil {
+		start = time.Now()
+	}
+	select {
+	case m.slot() <- struct{}{}:
+		m.acquired(start, true)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryLockFor waits at most d for the mutex and reports whether it got it
+func (m *CtxMutex) TryLockFor(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return m.LockContext(ctx) == nil
+}
+
+// Unlock releases the mutex
+func (m *CtxMutex) Unlock() {
+	traceReleased(m)
+	var hold time.Duration
+	if m.observer != nil {
+		hold = time.Since(m.acquiredAt)
+	}
+
+	select {
+	case <-m.slot():
+	default:
+		panic("CtxMutex: unlock of unlocked mutex")
+	}
+
+	if m.observer != nil {
+		m.observer.ObserveRelease(hold)
+	}
+}
+
+// CtxRWMutex is a reader/writer lock whose waiters can give up.
+// Waiting writers hold back new readers, like sync.RWMutex.
+// The zero value is unlocked.
+type CtxRWMutex struct {
+	mu             sync.Mutex
+	readers        int
+	writer         bool
+	writersWaiting int
+	wake           chan struct{} // Closed whenever something is released
+}
+
+// waitCh returns the channel the next release will close; rw.mu must be held
+func (rw *CtxRWMutex) waitCh() chan struct{} {
+	if rw.wake == nil {
+		rw.wake = make(chan struct{})
+	}
+	return rw.wake
+}
+
+// broadcast wakes every waiter to re-check the state; rw.mu must be held
+func (rw *CtxRWMutex) broadcast() {
+	if rw.wake != nil {
+		close(rw.wake)
+		rw.wake = nil
+	}
+}
+
+// wait parks until the next broadcast or ctx is done; rw.mu is held on
+// entry and on return
+func (rw *CtxRWMutex) wait(ctx context.Context) error {
+	wake := rw.waitCh()
+	rw.mu.Unlock()
+	defer rw.mu.Lock()
+
+	select {
+	case <-wake:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LockContext takes the write lock, giving up with ctx.Err() when ctx is done
+func (rw *CtxRWMutex) LockContext(ctx context.Context) error {
+	traceAcquiring(rw)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	rw.writersWaiting++
+	for rw.writer || rw.readers > 0 {
+		if err := rw.wait(ctx); err != nil {
+			rw.writersWaiting--
+			rw.broadcast() // Readers we were holding back may go now
+			return err
+		}
+	}
+	rw.writersWaiting--
+	rw.writer = true
+	traceAcquired(rw)
+	return nil
+}
+
+// RLockContext takes a read lock, giving up with ctx.Err() when ctx is done
+func (rw *CtxRWMutex) RLockContext(ctx context.Context) error {
+	traceAcquiring(rw)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	for rw.writer || rw.writersWaiting > 0 {
+		if err := rw.wait(ctx); err != nil {
+			return err
+		}
+	}
+	rw.readers++
+	traceAcquired(rw)
+	return nil
+}
+
+// Lock blocks until the write lock is free
+func (rw *CtxRWMutex) Lock() {
+	rw.LockContext(context.Background())
+}
+
+// RLock blocks until a read lock is available
+func (rw *CtxRWMutex) RLock() {
+	rw.RLockContext(context.Background())
+}
+
+// TryLock takes the write lock only if it is free right now
+func (rw *CtxRWMutex) TryLock() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.writer || rw.readers > 0 {
+		return false
+	}
+	rw.writer = true
+	traceAcquired(rw)
+	return true
+}
+
+// TryRLock takes a read lock only if no writer holds or waits for the lock
+func (rw *CtxRWMutex) TryRLock() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.writer || rw.writersWaiting > 0 {
+		return false
+	}
+	rw.readers++
+	traceAcquired(rw)
+	return true
+}
+
+// TryLockFor waits at most d for the write lock and reports whether it got it
+func (rw *CtxRWMutex) TryLockFor(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.LockContext(ctx) == nil
+}
+
+// TryRLockFor waits at most d for a read lock and reports whether it got it
+func (rw *CtxRWMutex) TryRLockFor(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.RLockContext(ctx) == nil
+}
+
+// Unlock releases the write lock
+func (rw *CtxRWMutex) Unlock() {
+	traceReleased(rw)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if !rw.writer {
+		panic("CtxRWMutex: unlock of unlocked mutex")
+	}
+	rw.writer = false
+	rw.broadcast()
+}
+
+// RUnlock releases one read lock
+func (rw *CtxRWMutex) RUnlock() {
+	traceReleased(rw)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.readers == 0 {
+		panic("CtxRWMutex: RUnlock of unlocked mutex")
+	}
+	rw.readers--
+	if rw.readers == 0 {
+		rw.broadcast()
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+type lockContender struct {
+	name string
+	run  func(ops int) // Acquire and release ops times
+}
+
+func main() {
+	fmt.Println("⏳ CONTEXT-AWARE LOCKS: WHAT DOES PATIENCE COST? ⏳")
+	fmt.Println(strings.Repeat("🕯️", 25))
+
+	const uncontendedOps = 1_000_000
+	const runs = 3
+
+	ctx := context.Background()
+	var mu sync.Mutex
+	var rw sync.RWMutex
+	var cm CtxMutex
+	var crw CtxRWMutex
+
+	contenders := []lockContender{
+		{"sync.Mutex", func(ops int) {
+			for i := 0; i < ops; i++ {
+				mu.Lock()
+				mu.Unlock()
+			}
+		}},
+		{"CtxMutex.Lock", func(ops int) {
+			for i := 0; i < ops; i++ {
+				cm.Lock()
+				cm.Unlock()
+			}
+		}},
+		{"CtxMutex.LockContext", func(ops int) {
+			for i := 0; i < ops; i++ {
+				cm.LockContext(ctx)
+				cm.Unlock()
+			}
+		}},
+		{"sync.RWMutex.Lock", func(ops int) {
+			for i := 0; i < ops; i++ {
+				rw.Lock()
+				rw.Unlock()
+			}
+		}},
+		{"CtxRWMutex.Lock", func(ops int) {
+			for i := 0; i < ops; i++ {
+				crw.Lock()
+				crw.Unlock()
+			}
+		}},
+		{"sync.RWMutex.RLock", func(ops int) {
+			for i := 0; i < ops; i++ {
+				rw.RLock()
+				rw.RUnlock()
+			}
+		}},
+		{"CtxRWMutex.RLock", func(ops int) {
+			for i := 0; i < ops; i++ {
+				crw.RLock()
+				crw.RUnlock()
+			}
+		}},
+	}
+
+	fmt.Printf("\nGOMAXPROCS=%d, mean of %d runs\n", runtime.GOMAXPROCS(0), runs)
+	fmt.Printf("\n%-22s %16s %18s\n", "lock", "uncontended ns", "50x1000 contended")
+
+	var baseUncontended, baseContended time.Duration
+	for i, c := range contenders {
+		var uncontended, contended time.Duration
+		for run := 0; run < runs; run++ {
+			start := time.Now()
+			c.run(uncontendedOps)
+			uncontended += time.Since(start)
+			contended += runContendedLock(c.run)
+		}
+		uncontended /= runs
+		contended /= runs
+
+		if i == 0 {
+			baseUncontended, baseContended = uncontended, contended
+		}
+		fmt.Printf("%-22s %10.2f (%.1fx) %12v (%.1fx)\n", c.name,
+			float64(uncontended.Nanoseconds())/uncontendedOps,
+			float64(uncontended)/float64(baseUncontended),
+			contended, float64(contended)/float64(baseContended))
+	}
+
+	fmt.Println("\n🔮 Ratios are against plain sync.Mutex.")
+	fmt.Println("The channel slot and broadcast wakeups are the price of being able to walk away -")
+	fmt.Println("like Ethel Cain keeping one hand on the trailer door the whole time.")
+}
+
+// runContendedLock is the familiar 50 goroutines x 1000 acquisitions
+func runContendedLock(run func(ops int)) time.Duration {
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(1000)
+		}()
+	}
+	wg.Wait()
+	return time.Since(start)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Neighbouring counters - 8 bytes each, so eight of them share one 64-byte line
+type slotUnpadded struct {
+	n uint64
+}
+
+// One counter per 64-byte line
+type slotPad64 struct {
+	n uint64
+	_ [56]byte
+}
+
+// One counter per 128 bytes - also beats the adjacent-line prefetcher
+type slotPad128 struct {
+	n uint64
+	_ [120]byte
+}
+
+type layout struct {
+	name  string
+	pad   int // Value accepted by -pad
+	size  int
+	slots func(g int) []*uint64 // Hands out one counter pointer per goroutine
+}
+
+func main() {
+	pad := flag.String("pad", "all", "padding to test: 0, 64, 128 or all")
+	flag.Parse()
+
+	const increments = 20_000_000 // Per goroutine
+	const runs = 3
+
+	lineSize := cacheLineSize()
+	goroutines := runtime.GOMAXPROCS(0)
+
+	layouts := []layout{
+		{"unpadded", 0, 8, func(g int) []*uint64 {
+			s := make([]slotUnpadded, g)
+			ptrs := make([]*uint64, g)
+			for i := range s {
+				ptrs[i] = &s[i].n
+			}
+			return ptrs
+		}},
+		{"padded 64B", 64, 64, func(g int) []*uint64 {
+			s := make([]slotPad64, g)
+			ptrs := make([]*uint64, g)
+			for i := range s {
+				ptrs[i] = &s[i].n
+			}
+			return ptrs
+		}},
+		{"padded 128B", 128, 128, func(g int) []*uint64 {
+			s := make([]slotPad128, g)
+			ptrs := make([]*uint64, g)
+			for i := range s {
+				ptrs[i] = &s[i].n
+			}
+			return ptrs
+		}},
+	}
+
+	if *pad != "all" {
+		size, err := strconv.Atoi(*pad)
+		var picked []layout
+		for _, l := range layouts {
+			if err == nil && l.pad == size {
+				picked = append(picked, l)
+			}
+		}
+		if len(picked) == 0 {
+			fmt.Fprintln(os.Stderr, "-pad must be 0, 64, 128 or all")
+			flag.Usage()
+			os.Exit(2)
+		}
+		layouts = picked
+	}
+
+	fmt.Println("👻 FALSE SHARING: THE HAUNTED CACHE LINE 👻")
+	fmt.Println(strings.Repeat("🕯️", 25))
+
+	fmt.Printf("\nDetected cache line: %d bytes\n", lineSize)
+	fmt.Printf("Goroutines: %d (one per P), %d increments each, mean of %d runs\n",
+		goroutines, increments, runs)
+	fmt.Println("Every goroutine owns its OWN counter - nobody shares data, only cache lines")
+
+	results := make([]time.Duration, len(layouts))
+	for i, l := range layouts {
+		fmt.Printf("\n🔮 %s (%d bytes per slot):\n", l.name, l.size)
+		var total time.Duration
+		for run := 1; run <= runs; run++ {
+			d := runFalseSharing(l.slots(goroutines), increments)
+			fmt.Printf("Run %d: %v\n", run, d)
+			total += d
+		}
+		results[i] = total / runs
+	}
+
+	fastest := results[0]
+	for _, d := range results {
+		fastest = min(fastest, d)
+	}
+
+	fmt.Println("\n" + strings.Repeat("🩸", 25))
+	fmt.Println("\n⚰️ THE CACHE LINE AUTOPSY ⚰️")
+	for i, l := range layouts {
+		marker := ""
+		if l.size >= lineSize {
+			marker = " (at least one full line per counter)"
+		}
+		fmt.Printf("%-12s %12v  %.2fx slower than best%s\n",
+			l.name, results[i], float64(results[i])/float64(fastest), marker)
+	}
+
+	if goroutines == 1 {
+		fmt.Println("\n🎭 Only one P - nobody else touches the line, so the ghost has no one to haunt.")
+		fmt.Println("Rerun on a multi-core machine to watch the cache line ping-pong between cores.")
+	} else {
+		fmt.Printf("\n💀 Unpadded counters pay %.2fx for ping-ponging one line between %d cores,\n",
+			float64(results[0])/float64(fastest), goroutines)
+		fmt.Println("like Eve Brown's sisters all grabbing the same diary to write in their OWN pages.")
+	}
+}
+
+// runFalseSharing lets each goroutine increment only its own counter
+func runFalseSharing(counters []*uint64, increments int) time.Duration {
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for _, p := range counters {
+		wg.Add(1)
+		go func(p *uint64) {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				*p++ // Plain store through a pointer - the compiler keeps it in memory
+			}
+		}(p)
+	}
+	wg.Wait()
+	duration := time.Since(start)
+
+	for _, p := range counters {
+		if *p != uint64(increments) {
+			panic(fmt.Sprintf("counter went missing: %d != %d", *p, increments))
+		}
+	}
+	return duration
+}
+
+// cacheLineSize reads the L1 coherency line size from sysfs, defaulting to 64
+func cacheLineSize() int {
+	raw, err := os.ReadFile("/sys/devices/system/cpu/cpu0/cache/index0/coherency_line_size")
+	if err != nil {
+		return 64
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil || size <= 0 {
+		return 64
+	}
+	return size
+}
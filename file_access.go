@@ -2,13 +2,38 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 func main() {
+	sweepMB := flag.Int("sweep-mb", 16, "MB written per buffer-size sweep point (0 skips the sweep)")
+	dir := flag.String("dir", ".", "directory for scratch files - pick the filesystem you care about")
+	durableLines := flag.Int("durable-lines", 10_000, "lines written per durability mode (0 skips them)")
+	syncEvery := flag.Int("sync-every", 100, "lines between syncs in the \"every N\" durability modes")
+	writers := flag.Int("writers", 50, "goroutines sharing the AsyncWriter")
+	flag.Parse()
+
+	if *syncEvery < 1 {
+		fmt.Fprintln(os.Stderr, "-sync-every must be at least 1")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *writers < 1 {
+		fmt.Fprintln(os.Stderr, "-writers must be at least 1")
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	fmt.Println("💀 FILE I/O HORROR SHOW 💀")
 	fmt.Println(strings.Repeat("🩸", 25))
 
@@ -17,27 +42,39 @@ func main() {
 
 	// Run each test 3 times for averages
 	var unbufferedTimes, bufferedTimes []time.Duration
+	var unbufferedWrites, bufferedWrites writeStats
 
 	for round := 1; round <= 3; round++ {
 		fmt.Printf("\n🕯️ SÉANCE ROUND %d 🕯️\n", round)
 
 		// Test 1: Unbuffered (Direct to Hell)
-		unbufferedTime := testUnbuffered(iterations, lineContent)
+		unbufferedTime, unbufferedStats := testUnbuffered(iterations, lineContent)
 		unbufferedTimes = append(unbufferedTimes, unbufferedTime)
+		unbufferedWrites = unbufferedStats
 
 		// Test 2: Buffered (With Protection Circle)
-		bufferedTime := testBuffered(iterations, lineContent)
+		bufferedTime, bufferedStats := testBuffered(iterations, lineContent)
 		bufferedTimes = append(bufferedTimes, bufferedTime)
+		bufferedWrites = bufferedStats
 
 		fmt.Printf("\n⚡ Speed Difference: %.2fx faster with buffer\n",
 			float64(unbufferedTime)/float64(bufferedTime))
 	}
 
+	if *sweepMB > 0 {
+		runBufferSweep(filepath.Join(*dir, "buffer_sweep.tmp"), *sweepMB<<20)
+	}
+	if *durableLines > 0 {
+		runDurabilityModes(filepath.Join(*dir, "durability.tmp"), *durableLines, lineContent, *syncEvery)
+	}
+
+	runAsyncWriterBench(filepath.Join(*dir, "async.tmp"), iterations, *durableLines, lineContent, *writers)
+
 	// Show the cursed truth
-	displayResults(unbufferedTimes, bufferedTimes)
+	displayResults(unbufferedTimes, bufferedTimes, unbufferedWrites, bufferedWrites)
 }
 
-func testUnbuffered(iterations int, content string) time.Duration {
+func testUnbuffered(iterations int, content string) (time.Duration, writeStats) {
 	fmt.Print("\n👻 UNBUFFERED WRITES (straight to the underworld)... ")
 
 	// Open the cursed tome
@@ -46,21 +83,28 @@ func testUnbuffered(iterations int, content string) time.Duration {
 		panic("Failed to open portal to disk dimension!")
 	}
 	defer file.Close()
+	counter := &syscallCounter{f: file}
+	ioBefore := readProcIO()
 
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// Each write goes DIRECTLY to disk - like texting Sexyy Red one letter at a time
 	for i := 0; i < iterations; i++ {
-		file.Write([]byte(content)) // Individual trip to hell each time!
+		counter.Write([]byte(content)) // Individual trip to hell each time!
 	}
 
 	duration := time.Since(startTime)
+	sched := probe.Stop()
+	stats := counter.stats(readProcIO().sub(ioBefore))
 	fmt.Printf("Complete! Time: %v\n", duration)
+	fmt.Println(stats.Summary())
+	fmt.Println(sched.Summary())
 
-	return duration
+	return duration, stats
 }
 
-func testBuffered(iterations int, content string) time.Duration {
+func testBuffered(iterations int, content string) (time.Duration, writeStats) {
 	fmt.Print("\n✨ BUFFERED WRITES (collecting souls first)... ")
 
 	// Open another cursed tome
@@ -71,8 +115,11 @@ func testBuffered(iterations int, content string) time.Duration {
 	defer file.Close()
 
 	// Wrap in protective buffer magic - like Eve Brown's organizational system
-	writer := bufio.NewWriter(file)
+	counter := &syscallCounter{f: file}
+	writer := bufio.NewWriter(counter)
+	ioBefore := readProcIO()
 
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// Writes go to memory first - like collecting verses before recording
@@ -84,12 +131,364 @@ func testBuffered(iterations int, content string) time.Duration {
 	writer.Flush() // Like dropping the whole Ethel Cain album at once
 
 	duration := time.Since(startTime)
+	sched := probe.Stop()
+	stats := counter.stats(readProcIO().sub(ioBefore))
 	fmt.Printf("Complete! Time: %v\n", duration)
+	fmt.Println(stats.Summary())
+	fmt.Println(sched.Summary())
+
+	return duration, stats
+}
+
+// syscallCounter sits between a bufio.Writer and the file and counts every
+// Write that gets through - each one is a write(2) syscall
+type syscallCounter struct {
+	f      *os.File
+	writes int64
+	bytes  int64
+}
+
+func (c *syscallCounter) Write(p []byte) (int, error) {
+	c.writes++
+	n, err := c.f.Write(p)
+	c.bytes += int64(n)
+	return n, err
+}
+
+func (c *syscallCounter) stats(kernel procIO) writeStats {
+	return writeStats{writes: c.writes, bytes: c.bytes, kernel: kernel}
+}
+
+// writeStats is one test's write(2) count, from our own wrapper and from
+// the kernel's accounting
+type writeStats struct {
+	writes int64
+	bytes  int64
+	kernel procIO
+}
+
+func (w writeStats) Summary() string {
+	perCall := 0.0
+	if w.writes > 0 {
+		perCall = float64(w.bytes) / float64(w.writes)
+	}
+	return fmt.Sprintf("🔢 write(2): %d counted, %d per /proc/self/io | %.0f bytes/syscall | wchar +%d, write_bytes +%d",
+		w.writes, w.kernel.syscw, perCall, w.kernel.wchar, w.kernel.writeBytes)
+}
+
+// procIO is the kernel's I/O accounting for this whole process. wchar is
+// what we handed to write(2); write_bytes is what has reached the block
+// layer, which stays near zero until writeback gets around to it.
+type procIO struct {
+	syscw      int64
+	wchar      int64
+	writeBytes int64
+}
+
+// readProcIO returns zeros where /proc/self/io doesn't exist
+func readProcIO() procIO {
+	var io procIO
+	data, err := os.ReadFile("/proc/self/io")
+	if err != nil {
+		return io
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch key {
+		case "syscw":
+			io.syscw = n
+		case "wchar":
+			io.wchar = n
+		case "write_bytes":
+			io.writeBytes = n
+		}
+	}
+	return io
+}
+
+func (a procIO) sub(b procIO) procIO {
+	return procIO{a.syscw - b.syscw, a.wchar - b.wchar, a.writeBytes - b.writeBytes}
+}
+
+// runBufferSweep writes total bytes for every buffer size / line size pair
+// and reports where bigger buffers stop paying off
+func runBufferSweep(path string, total int) {
+	fmt.Println("\n" + strings.Repeat("🕯️", 25))
+	fmt.Printf("\n🔮 BUFFER SIZE SWEEP: %d MB per point into %s, best of 3\n", total>>20, path)
+	defer os.Remove(path)
+
+	var bufferSizes []int
+	for size := 512; size <= 1<<20; size *= 2 {
+		bufferSizes = append(bufferSizes, size)
+	}
+	lineSizes := []int{16, 256, 4 << 10, 64 << 10}
+
+	for _, lineSize := range lineSizes {
+		line := append(bytes.Repeat([]byte{'~'}, lineSize-1), '\n')
+		lines := total / lineSize
 
-	return duration
+		fmt.Printf("\nLines of %s:\n", byteSize(lineSize))
+		fmt.Printf("%10s %10s %14s\n", "buffer", "MB/s", "syscalls/MB")
+
+		rates := make([]float64, len(bufferSizes))
+		for i, bufSize := range bufferSizes {
+			// Best of 3 - the page cache is a moody spirit
+			var fastest time.Duration
+			var counter *syscallCounter
+			for attempt := 0; attempt < 3; attempt++ {
+				file, err := os.Create(path)
+				if err != nil {
+					panic("Failed to open portal to disk dimension!")
+				}
+				counter = &syscallCounter{f: file}
+				writer := bufio.NewWriterSize(counter, bufSize)
+
+				startTime := time.Now()
+				for j := 0; j < lines; j++ {
+					writer.Write(line)
+				}
+				writer.Flush()
+				duration := time.Since(startTime)
+				file.Close()
+
+				if attempt == 0 || duration < fastest {
+					fastest = duration
+				}
+			}
+
+			mb := float64(lines*lineSize) / (1 << 20)
+			rates[i] = mb / fastest.Seconds()
+			fmt.Printf("%10s %10.0f %14.1f\n", byteSize(bufSize), rates[i], float64(counter.writes)/mb)
+		}
+
+		// The knee: the smallest buffer within 10% of the best rate
+		best := 0.0
+		for _, r := range rates {
+			best = max(best, r)
+		}
+		for i, r := range rates {
+			if r >= 0.9*best {
+				fmt.Printf("🦴 Knee: %s - bigger buffers buy less than 10%% more\n", byteSize(bufferSizes[i]))
+				break
+			}
+		}
+	}
+}
+
+// durabilityMode is one answer to "when is the line actually on disk?"
+type durabilityMode struct {
+	name      string
+	openFlags int                    // Extra flags, e.g. O_SYNC
+	sync      func(f *os.File) error // Called every syncEvery lines, or once at the end
+	atEndOnly bool
+	eachWrite bool // The write itself waits for the disk - time every one
+}
+
+// runDurabilityModes writes lines one write(2) at a time, like
+// testUnbuffered, and times every sync
+func runDurabilityModes(path string, lines int, content string, syncEvery int) {
+	fmt.Println("\n" + strings.Repeat("🕯️", 25))
+	fmt.Printf("\n⚰️ DURABILITY MODES: %d unbuffered lines into %s\n", lines, path)
+	defer os.Remove(path)
+
+	modes := []durabilityMode{
+		{name: "no sync (page cache only)"},
+		{name: "fsync at end", sync: (*os.File).Sync, atEndOnly: true},
+		{name: fmt.Sprintf("fsync every %d", syncEvery), sync: (*os.File).Sync},
+		{name: fmt.Sprintf("fdatasync every %d", syncEvery), sync: fdatasync},
+		{name: "O_SYNC", openFlags: syscall.O_SYNC, eachWrite: true},
+		{name: "O_DSYNC", openFlags: syscall.O_DSYNC, eachWrite: true},
+	}
+
+	fmt.Printf("\n%-26s %12s %9s %8s %10s %10s %10s\n", "mode", "lines/sec", "MB/s", "syncs", "sync p50", "sync p99", "sync max")
+	for _, m := range modes {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|m.openFlags, 0o644)
+		if err != nil {
+			panic("Failed to open portal to disk dimension: " + err.Error())
+		}
+
+		var syncs []time.Duration
+		timedSync := func() {
+			start := time.Now()
+			if err := m.sync(file); err != nil {
+				panic("Sync refused: " + err.Error())
+			}
+			syncs = append(syncs, time.Since(start))
+		}
+
+		line := []byte(content)
+		startTime := time.Now()
+		for i := 0; i < lines; i++ {
+			writeStart := time.Now()
+			file.Write(line)
+			if m.eachWrite {
+				syncs = append(syncs, time.Since(writeStart))
+			}
+			if m.sync != nil && !m.atEndOnly && (i+1)%syncEvery == 0 {
+				timedSync()
+			}
+		}
+		if m.atEndOnly {
+			timedSync()
+		}
+		duration := time.Since(startTime)
+		file.Close()
+
+		p50, p99, worst := "-", "-", "-"
+		if len(syncs) > 0 {
+			slices.Sort(syncs)
+			p50 = syncs[len(syncs)*50/100].Round(time.Microsecond).String()
+			p99 = syncs[len(syncs)*99/100].Round(time.Microsecond).String()
+			worst = syncs[len(syncs)-1].Round(time.Microsecond).String()
+		}
+		fmt.Printf("%-26s %12.0f %9.2f %8d %10s %10s %10s\n", m.name,
+			float64(lines)/duration.Seconds(), float64(lines*len(line))/(1<<20)/duration.Seconds(),
+			len(syncs), p50, p99, worst)
+	}
+
+	fmt.Println("\n🩸 Until something syncs, the \"disk\" in every other number here is the page")
+	fmt.Println("cache. The sync columns are what a real trip to the platters costs.")
 }
 
-func displayResults(unbufferedTimes, bufferedTimes []time.Duration) {
+// runAsyncWriterBench puts AsyncWriter next to the plain modes above: first
+// page-cache speed, then every line durable before its writer moves on
+func runAsyncWriterBench(path string, lines, durableLines int, content string, writers int) {
+	fmt.Println("\n" + strings.Repeat("🕯️", 25))
+	fmt.Printf("\n📮 ASYNC WRITER vs THE CLASSICS: %d lines, %d writers for the shared rows\n", lines, writers)
+	defer os.Remove(path)
+
+	line := []byte(content)
+	type asyncRow struct {
+		name    string
+		lines   int
+		durable bool
+		run     func(f *os.File, lines int) AsyncWriterStats
+	}
+
+	// split runs body once per writer goroutine, dividing the lines between them
+	split := func(lines int, body func(n int)) {
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			n := lines / writers
+			if w < lines%writers {
+				n++
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				body(n)
+			}()
+		}
+		wg.Wait()
+	}
+
+	rows := []asyncRow{
+		{"unbuffered, 1 goroutine", lines, false, func(f *os.File, lines int) AsyncWriterStats {
+			for i := 0; i < lines; i++ {
+				f.Write(line)
+			}
+			return AsyncWriterStats{Batches: uint64(lines)}
+		}},
+		{"bufio.Writer, 1 goroutine", lines, false, func(f *os.File, lines int) AsyncWriterStats {
+			counter := &syscallCounter{f: f}
+			writer := bufio.NewWriter(counter)
+			for i := 0; i < lines; i++ {
+				writer.Write(line)
+			}
+			writer.Flush()
+			return AsyncWriterStats{Batches: uint64(counter.writes)}
+		}},
+		{"AsyncWriter, 1 goroutine", lines, false, func(f *os.File, lines int) AsyncWriterStats {
+			aw := NewAsyncWriter(f, AsyncWriterConfig{MaxDelay: time.Millisecond})
+			for i := 0; i < lines; i++ {
+				aw.Write(line)
+			}
+			aw.Close()
+			return aw.Stats()
+		}},
+		{"AsyncWriter, shared", lines, false, func(f *os.File, lines int) AsyncWriterStats {
+			aw := NewAsyncWriter(f, AsyncWriterConfig{MaxDelay: time.Millisecond})
+			split(lines, func(n int) {
+				for i := 0; i < n; i++ {
+					aw.Write(line)
+				}
+			})
+			aw.Close()
+			return aw.Stats()
+		}},
+		{"mutex + fsync per line", durableLines, true, func(f *os.File, lines int) AsyncWriterStats {
+			var mu sync.Mutex
+			split(lines, func(n int) {
+				for i := 0; i < n; i++ {
+					mu.Lock()
+					f.Write(line)
+					f.Sync()
+					mu.Unlock()
+				}
+			})
+			return AsyncWriterStats{Batches: uint64(lines), Syncs: uint64(lines)}
+		}},
+		{"AsyncWriter, group commit", durableLines, true, func(f *os.File, lines int) AsyncWriterStats {
+			aw := NewAsyncWriter(f, AsyncWriterConfig{Sync: true, MaxDelay: 200 * time.Microsecond})
+			split(lines, func(n int) {
+				for i := 0; i < n; i++ {
+					if err := aw.WriteAsync(line).Wait(); err != nil {
+						panic("Group commit failed: " + err.Error())
+					}
+				}
+			})
+			aw.Close()
+			return aw.Stats()
+		}},
+	}
+
+	fmt.Printf("\n%-28s %8s %12s %12s %9s %8s %8s %11s %11s\n",
+		"mode", "lines", "time", "lines/sec", "durable", "batches", "syncs", "delay p99", "mutex wait")
+	for _, r := range rows {
+		if r.lines == 0 {
+			continue
+		}
+		file, err := os.Create(path)
+		if err != nil {
+			panic("Failed to open portal to disk dimension!")
+		}
+		probe := StartSchedProbe()
+		startTime := time.Now()
+		stats := r.run(file, r.lines)
+		duration := time.Since(startTime)
+		sched := probe.Stop()
+		file.Close()
+
+		durable := "no"
+		if r.durable {
+			durable = "per line"
+		}
+		fmt.Printf("%-28s %8d %12v %12.0f %9s %8d %8d %11s %11s\n", r.name, r.lines, duration.Round(time.Microsecond),
+			float64(r.lines)/duration.Seconds(), durable, stats.Batches, stats.Syncs,
+			"≤"+shortDuration(sched.DelayPercentile(0.99)), shortDuration(sched.MutexWait))
+	}
+
+	fmt.Println("\n🔮 Group commit: while one fsync is in flight the next batch piles up behind it,")
+	fmt.Println("so a whole coven of writers shares each trip to the disk.")
+}
+
+// byteSize prints 512B, 4KB, 1MB
+func byteSize(n int) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
+
+func displayResults(unbufferedTimes, bufferedTimes []time.Duration, unbufferedWrites, bufferedWrites writeStats) {
 	fmt.Println("\n" + strings.Repeat("💀", 25))
 	fmt.Println("\n🩸 THE HORRIFYING TRUTH ABOUT DISK I/O 🩸")
 
@@ -108,6 +507,12 @@ func displayResults(unbufferedTimes, bufferedTimes []time.Duration) {
 	fmt.Printf("\nSpeed difference: %.2fx faster with buffering!\n",
 		float64(unbuffAvg)/float64(buffAvg))
 
+	fmt.Printf("\n🔢 KERNEL CROSSINGS (last round):\n")
+	fmt.Printf("Unbuffered: %d write syscalls, %.0f bytes each\n",
+		unbufferedWrites.writes, float64(unbufferedWrites.bytes)/float64(max(unbufferedWrites.writes, 1)))
+	fmt.Printf("Buffered: %d write syscalls, %.0f bytes each\n",
+		bufferedWrites.writes, float64(bufferedWrites.bytes)/float64(max(bufferedWrites.writes, 1)))
+
 	fmt.Print(`
 ╔════════════════════════════════════════════════════════════╗
 ║              🔮 THE DISK I/O NIGHTMARE 🔮                   ║
@@ -120,7 +525,7 @@ func displayResults(unbufferedTimes, bufferedTimes []time.Duration) {
 ║  • Good for: Literally nothing except pain                ║
 ║                                                            ║
 ║  BUFFERED (The FKA twigs Choreographed Approach):        ║
-║  • Collects writes in memory (usually 4KB buffer)         ║
+║  • Collects writes in memory (4KB by default - see sweep) ║
 ║  • Like writing your whole message before hitting send    ║
 ║  • One efficient disk journey when buffer fills/flushes   ║
 ║  • Good for: Not wanting to die of old age                ║
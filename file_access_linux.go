@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fdatasync flushes the data and only the metadata needed to read it back,
+// skipping the mtime update that fsync also waits for
+func fdatasync(f *os.File) error {
+	return syscall.Fdatasync(int(f.Fd()))
+}
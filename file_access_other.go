@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// fdatasync falls back to a full Sync where the syscall doesn't exist, so
+// the "fdatasync every N" row matches the fsync one
+func fdatasync(f *os.File) error {
+	return f.Sync()
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func main() {
+	budgetMiB := flag.Int("budget", 0, "memory budget for parked goroutines in MiB (default: half of MemAvailable)")
+	pinnedMax := flag.Int("pinned-max", 5_000, "largest LockOSThread sweep - each one costs a real OS thread")
+	flag.Parse()
+
+	fmt.Println("🧟 GOROUTINE SUMMONING COSTS 🧟")
+	fmt.Println(strings.Repeat("🕯️", 25))
+
+	budget := uint64(*budgetMiB) << 20
+	if budget == 0 {
+		budget = memAvailable() / 2
+	}
+	counts := []int{1_000, 10_000, 100_000, 1_000_000}
+
+	fmt.Printf("\nGOMAXPROCS=%d, parked-goroutine budget %d MiB\n", runtime.GOMAXPROCS(0), budget>>20)
+
+	// 1. Spawn + join
+	fmt.Println("\n🔮 EXPERIMENT 1: SPAWN AND JOIN (goroutine does nothing but wg.Done)")
+	fmt.Printf("%-10s %14s %14s %14s\n", "goroutines", "spawn ns/g", "join ns/g", "total ns/g")
+	for _, n := range counts {
+		spawn, join := measureSpawnJoin(n)
+		fmt.Printf("%-10d %14.1f %14.1f %14.1f\n", n,
+			perGoroutine(spawn, n), perGoroutine(join, n), perGoroutine(spawn+join, n))
+	}
+
+	// 2. Parked goroutines and memory
+	fmt.Println("\n💀 EXPERIMENT 2: HOW MANY CAN WE KEEP ALIVE? (all parked on one channel)")
+	fmt.Printf("%-10s %14s %14s %14s\n", "goroutines", "Sys B/g", "stack B/g", "spawn ns/g")
+	var bytesPerG uint64
+	for _, n := range counts {
+		if bytesPerG > 0 && uint64(n)*bytesPerG > budget {
+			fmt.Printf("%-10d skipped - would need ~%d MiB\n", n, uint64(n)*bytesPerG>>20)
+			continue
+		}
+		sys, stack, spawn := measureParked(n)
+		bytesPerG = max(sys, stack)
+		fmt.Printf("%-10d %14d %14d %14.1f\n", n, sys, stack, perGoroutine(spawn, n))
+	}
+	if bytesPerG > 0 {
+		fmt.Printf("≈ %d parked goroutines fit in the %d MiB budget before memory pressure\n",
+			budget/bytesPerG, budget>>20)
+	}
+
+	// 3. Stack growth
+	fmt.Println("\n🩸 EXPERIMENT 3: STACK GROWTH (recursing through ~40KB of frames)")
+	fmt.Printf("%-10s %14s %14s %14s\n", "goroutines", "flat ns/g", "deep ns/g", "extra ns/g")
+	for _, n := range counts {
+		flat := measureStackGrowth(n, 0)
+		deep := measureStackGrowth(n, 256)
+		fmt.Printf("%-10d %14.1f %14.1f %14.1f\n", n,
+			perGoroutine(flat, n), perGoroutine(deep, n), perGoroutine(deep-flat, n))
+	}
+
+	// 4. OS-thread-pinned goroutines
+	// Every pinned goroutine can be holding its own thread at once, and the
+	// runtime crashes the whole process past debug.SetMaxThreads (10,000 by
+	// default), so the sweep stays under half of that whatever -pinned-max says
+	fmt.Println("\n⚰️ EXPERIMENT 4: LockOSThread AND EXIT (every goroutine burns a real OS thread)")
+	threadCap := maxThreads() / 2
+	if *pinnedMax > threadCap {
+		fmt.Printf("(-pinned-max %d clamped to %d, half of debug.SetMaxThreads)\n", *pinnedMax, threadCap)
+		*pinnedMax = threadCap
+	}
+	fmt.Printf("%-10s %14s %14s\n", "goroutines", "plain ns/g", "pinned ns/g")
+	for _, n := range []int{1_000, 2_000, 5_000, 10_000} {
+		if n > *pinnedMax {
+			fmt.Printf("%-10d skipped - over -pinned-max, and every one would be a live OS thread\n", n)
+			continue
+		}
+		plain, _ := measureSpawnJoin(n)
+		pinned := measurePinned(n)
+		fmt.Printf("%-10d %14.1f %14.1f\n", n, perGoroutine(plain, n), perGoroutine(pinned, n))
+	}
+
+	fmt.Println("\n🔮 THE VERDICT: goroutines ARE cheap spirits - a few KB of stack and a")
+	fmt.Println("few hundred ns to summon. The moment you pin one to an OS thread, you pay")
+	fmt.Println("for a whole body - like Ayesha trading a personality switch for a full exorcism.")
+}
+
+func perGoroutine(d time.Duration, n int) float64 {
+	return float64(d.Nanoseconds()) / float64(n)
+}
+
+// measureSpawnJoin times issuing n go statements, then waiting for them all
+func measureSpawnJoin(n int) (spawn, join time.Duration) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		go func() {
+			wg.Done()
+		}()
+	}
+	spawned := time.Now()
+	wg.Wait()
+
+	return spawned.Sub(start), time.Since(spawned)
+}
+
+// measureParked keeps n goroutines alive at once and reports memory per goroutine
+func measureParked(n int) (sysPerG, stackPerG uint64, spawn time.Duration) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	block := make(chan struct{})
+	var ready, done sync.WaitGroup
+	ready.Add(n)
+	done.Add(n)
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		go func() {
+			defer done.Done()
+			ready.Done()
+			<-block // Haunting the channel forever (well, until close)
+		}()
+	}
+	ready.Wait()
+	spawn = time.Since(start)
+
+	runtime.ReadMemStats(&after)
+	close(block)
+	done.Wait()
+
+	return (after.Sys - before.Sys) / uint64(n), (after.StackInuse - before.StackInuse) / uint64(n), spawn
+}
+
+// measureStackGrowth spawns n goroutines that each recurse depth frames deep
+func measureStackGrowth(n, depth int) time.Duration {
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			descend(depth)
+		}()
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+// descend burns ~160 bytes of stack per frame, forcing the runtime to copy
+// the stack to a bigger one every time it runs out
+//
+//go:noinline
+func descend(depth int) byte {
+	var frame [128]byte
+	frame[depth%len(frame)] = byte(depth)
+	if depth == 0 {
+		return frame[0]
+	}
+	return descend(depth-1) + frame[depth%len(frame)]
+}
+
+// measurePinned spawns n goroutines that lock their thread and exit still
+// locked, so the runtime must throw the thread away and make a new one
+func measurePinned(n int) time.Duration {
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			runtime.LockOSThread() // Never unlocked - the thread dies with us
+		}()
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+// maxThreads reads the runtime's thread limit; SetMaxThreads is the only
+// way to ask, so it puts the old value straight back
+func maxThreads() int {
+	limit := debug.SetMaxThreads(10_000)
+	debug.SetMaxThreads(limit)
+	return limit
+}
+
+// memAvailable reads MemAvailable from /proc/meminfo, defaulting to 2 GiB
+func memAvailable() uint64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 2 << 30
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err == nil {
+				return kb << 10
+			}
+		}
+	}
+	return 2 << 30
+}
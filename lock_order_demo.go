@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SafeMap with a CtxMutex, so the lock debugger can see it
+type SafeMap struct {
+	id int // Fixed lock rank for the well-behaved transfer
+	mu CtxMutex
+	m  map[int]int
+}
+
+func main() {
+	fmt.Println("🔍 LOCK ORDER INQUISITION 🔍")
+	fmt.Println(strings.Repeat("🕯️", 25))
+
+	if lockTracer == nil {
+		fmt.Println("\n⚠️ lockdebug.go is not compiled in - nothing will be reported.")
+		fmt.Println("Run: go run lock_order_demo.go ctxlock.go lockstats.go lockdebug.go")
+	}
+
+	vault := &SafeMap{id: 1, m: map[int]int{1: 100}}
+	crypt := &SafeMap{id: 2, m: map[int]int{1: 100}}
+
+	// Two transfers that take the locks in opposite orders. They run one
+	// after the other, so this run never hangs - but with real traffic they
+	// would eventually grab one lock each and wait forever.
+	fmt.Println("\n💀 CURSED: transfers that lock source first, then destination")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		transferSourceFirst(vault, crypt, 1, 10)
+	}()
+	wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		transferSourceFirst(crypt, vault, 1, 10)
+	}()
+	wg.Wait()
+
+	// Same transfers, but always locking the lower-ranked map first
+	fmt.Println("\n✨ BLESSED: transfers that lock by rank - no cycle, no report")
+	ledger := &SafeMap{id: 3, m: map[int]int{1: 100}}
+	tome := &SafeMap{id: 4, m: map[int]int{1: 100}}
+	transferRanked(ledger, tome, 1, 10)
+	transferRanked(tome, ledger, 1, 10)
+
+	// A holder that dawdles past the threshold
+	fmt.Println("\n🐌 SLOW: holding a lock for 150ms")
+	tome.mu.Lock()
+	time.Sleep(150 * time.Millisecond)
+	tome.mu.Unlock()
+
+	fmt.Printf("\nBalances: vault=%d crypt=%d ledger=%d tome=%d\n",
+		vault.m[1], crypt.m[1], ledger.m[1], tome.m[1])
+}
+
+// transferSourceFirst is the classic bug - lock order depends on the arguments
+func transferSourceFirst(from, to *SafeMap, key, amount int) {
+	from.mu.Lock()
+	defer from.mu.Unlock()
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	from.m[key] -= amount
+	to.m[key] += amount
+}
+
+// transferRanked always locks the map with the lower id first
+func transferRanked(from, to *SafeMap, key, amount int) {
+	first, second := from, to
+	if second.id < first.id {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	from.m[key] -= amount
+	to.m[key] += amount
+}
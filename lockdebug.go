@@ -0,0 +1,244 @@
+//go:build lockdebug
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opt-in lock debugging for CtxMutex/CtxRWMutex and the Instrumented
+// mutexes in lockstats.go. Compile this file in (list it next to
+// lockstats.go with go run - a file list ignores -tags - or use
+// -tags lockdebug for a package build) and every acquisition is recorded
+// per goroutine. "Held A, then waited for B" becomes an edge A -> B in a
+// lock-order graph; any cycle is a deadlock waiting to happen, even if this
+// run got lucky. Locks held longer than LOCKDEBUG_HOLD (default 100ms) are
+// flagged on release.
+
+func init() {
+	threshold := 100 * time.Millisecond
+	if v := os.Getenv("LOCKDEBUG_HOLD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			panic("LOCKDEBUG_HOLD must be a duration like 50ms: " + err.Error())
+		}
+		threshold = d
+	}
+	lockTracer = &lockOrderTracker{
+		held:      make(map[int64][]heldLock),
+		edges:     make(map[lockEdge]edgeSite),
+		reported:  make(map[string]bool),
+		threshold: threshold,
+	}
+}
+
+type heldLock struct {
+	lock  any
+	since time.Time
+	stack string // Where it was acquired
+}
+
+type lockEdge struct {
+	from, to any
+}
+
+// edgeSite remembers where an edge was first seen
+type edgeSite struct {
+	fromStack string // Where "from" was acquired
+	toStack   string // Where "to" was requested while "from" was held
+}
+
+type lockOrderTracker struct {
+	mu        sync.Mutex
+	held      map[int64][]heldLock // Goroutine ID -> locks in acquisition order
+	edges     map[lockEdge]edgeSite
+	reported  map[string]bool // Each cycle is only reported once
+	threshold time.Duration
+}
+
+func (t *lockOrderTracker) acquiring(lock any) {
+	gid := goroutineID()
+	stack := callerStack()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, h := range t.held[gid] {
+		if h.lock == lock {
+			t.report("recursive:"+lockName(lock), fmt.Sprintf(
+				"goroutine %d requests %s while already holding it\n\nfirst acquired at:\n%s\nrequested again at:\n%s",
+				gid, lockName(lock), h.stack, stack))
+			continue
+		}
+
+		edge := lockEdge{from: h.lock, to: lock}
+		if _, seen := t.edges[edge]; !seen {
+			t.edges[edge] = edgeSite{fromStack: h.stack, toStack: stack}
+		}
+
+		// Closing the loop: can we already get from lock back to h.lock?
+		if path := t.path(lock, h.lock); path != nil {
+			t.reportCycle(append([]any{h.lock}, path...))
+		}
+	}
+}
+
+func (t *lockOrderTracker) acquired(lock any) {
+	gid := goroutineID()
+	stack := callerStack()
+
+	t.mu.Lock()
+	t.held[gid] = append(t.held[gid], heldLock{lock: lock, since: time.Now(), stack: stack})
+	t.mu.Unlock()
+}
+
+func (t *lockOrderTracker) released(lock any) {
+	gid := goroutineID()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.drop(gid, lock)
+	if !ok {
+		// sync.Mutex semantics allow unlocking from another goroutine
+		for other := range t.held {
+			if h, ok = t.drop(other, lock); ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return
+	}
+
+	if hold := time.Since(h.since); hold > t.threshold {
+		fmt.Fprintf(os.Stderr, "\n🐌 LOCKDEBUG: %s held for %v (threshold %v)\nacquired at:\n%s\nreleased at:\n%s\n",
+			lockName(lock), hold, t.threshold, h.stack, callerStack())
+	}
+}
+
+// drop removes the most recent acquisition of lock by gid
+func (t *lockOrderTracker) drop(gid int64, lock any) (heldLock, bool) {
+	locks := t.held[gid]
+	for i := len(locks) - 1; i >= 0; i-- {
+		if locks[i].lock == lock {
+			h := locks[i]
+			locks = append(locks[:i], locks[i+1:]...)
+			if len(locks) == 0 {
+				delete(t.held, gid)
+			} else {
+				t.held[gid] = locks
+			}
+			return h, true
+		}
+	}
+	return heldLock{}, false
+}
+
+// path returns the locks on some edge path from -> ... -> to, or nil
+func (t *lockOrderTracker) path(from, to any) []any {
+	visited := map[any]bool{from: true}
+	var walk func(cur any) []any
+	walk = func(cur any) []any {
+		if cur == to {
+			return []any{cur}
+		}
+		for e := range t.edges {
+			if e.from != cur || visited[e.to] {
+				continue
+			}
+			visited[e.to] = true
+			if rest := walk(e.to); rest != nil {
+				return append([]any{cur}, rest...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
+
+// reportCycle prints a cycle like A -> B -> A with the stacks behind each edge
+func (t *lockOrderTracker) reportCycle(cycle []any) {
+	names := make([]string, len(cycle))
+	for i, l := range cycle {
+		names[i] = lockName(l)
+	}
+
+	// The same cycle can be found starting from any of its locks, so key it
+	// by the rotation that starts at the smallest name
+	ring := names[:len(names)-1]
+	start := 0
+	for i := range ring {
+		if ring[i] < ring[start] {
+			start = i
+		}
+	}
+	key := strings.Join(append(append([]string(nil), ring[start:]...), ring[:start]...), ",")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "potential deadlock, lock order cycle: %s\n", strings.Join(names, " -> "))
+	for i := 0; i+1 < len(cycle); i++ {
+		site := t.edges[lockEdge{from: cycle[i], to: cycle[i+1]}]
+		fmt.Fprintf(&b, "\n%s acquired at:\n%s\nthen %s requested at:\n%s",
+			names[i], site.fromStack, names[i+1], site.toStack)
+	}
+	t.report("cycle:"+key, b.String())
+}
+
+func (t *lockOrderTracker) report(key, msg string) {
+	if t.reported[key] {
+		return
+	}
+	t.reported[key] = true
+	fmt.Fprintf(os.Stderr, "\n💀 LOCKDEBUG: %s\n", msg)
+}
+
+func lockName(lock any) string {
+	return fmt.Sprintf("%T(%p)", lock, lock)
+}
+
+// goroutineID parses the "goroutine 42 [running]:" header of our own stack
+func goroutineID() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	field := bytes.Fields(buf[:n])[1]
+	id, err := strconv.ParseInt(string(field), 10, 64)
+	if err != nil {
+		panic("lockdebug: cannot parse goroutine ID from " + string(buf[:n]))
+	}
+	return id
+}
+
+// callerStack formats the stack above the lock methods and this tracker
+func callerStack() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		f, more := frames.Next()
+		name := f.Function
+		internal := strings.Contains(name, "lockOrderTracker") ||
+			strings.HasPrefix(name, "main.trace") ||
+			strings.HasPrefix(name, "main.callerStack") ||
+			strings.HasPrefix(name, "main.(*CtxMutex)") ||
+			strings.HasPrefix(name, "main.(*CtxRWMutex)") ||
+			strings.HasPrefix(name, "main.(*InstrumentedMutex)") ||
+			strings.HasPrefix(name, "main.(*InstrumentedRWMutex)")
+		if !internal && !strings.HasPrefix(name, "runtime.") {
+			fmt.Fprintf(&b, "    %s\n        %s:%d\n", name, f.File, f.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lockHistBuckets covers 0ns up to ~2.3 minutes in powers of two
+const lockHistBuckets = 48
+
+// lockHistogram buckets durations by powers of two nanoseconds: bucket b
+// holds [2^(b-1), 2^b), bucket 0 holds exactly zero
+type lockHistogram struct {
+	buckets [lockHistBuckets]atomic.Uint64
+	count   atomic.Uint64
+	total   atomic.Int64
+	max     atomic.Int64
+}
+
+func (h *lockHistogram) record(d time.Duration) {
+	h.recordN(d, 1)
+}
+
+// recordN records n samples of d at once
+func (h *lockHistogram) recordN(d time.Duration, n uint64) {
+	if n == 0 {
+		return
+	}
+	ns := max(int64(d), 0)
+	b := min(bits.Len64(uint64(ns)), lockHistBuckets-1)
+	h.buckets[b].Add(n)
+	h.count.Add(n)
+	h.total.Add(ns * int64(n))
+	for {
+		cur := h.max.Load()
+		if ns <= cur || h.max.CompareAndSwap(cur, ns) {
+			return
+		}
+	}
+}
+
+// percentile returns the upper edge of the bucket holding the p-th value
+func (h *lockHistogram) percentile(p float64) time.Duration {
+	n := h.count.Load()
+	if n == 0 {
+		return 0
+	}
+	target := uint64(p*float64(n) + 0.5)
+	var seen uint64
+	for b := range h.buckets {
+		seen += h.buckets[b].Load()
+		if seen >= max(target, 1) {
+			if b == 0 {
+				return 0
+			}
+			return time.Duration(uint64(1) << b)
+		}
+	}
+	return time.Duration(h.max.Load())
+}
+
+func (h *lockHistogram) mean() time.Duration {
+	n := h.count.Load()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(h.total.Load() / int64(n))
+}
+
+// bars draws one line per non-empty bucket
+func (h *lockHistogram) bars(indent string) string {
+	var peak uint64
+	for b := range h.buckets {
+		peak = max(peak, h.buckets[b].Load())
+	}
+	var sb strings.Builder
+	for b := range h.buckets {
+		n := h.buckets[b].Load()
+		if n == 0 {
+			continue
+		}
+		width := int(40 * n / peak)
+		label := "0"
+		if b > 0 {
+			label = "<" + shortDuration(time.Duration(uint64(1)<<b))
+		}
+		fmt.Fprintf(&sb, "%s%-8s %-40s %d\n", indent, label, strings.Repeat("█", max(width, 1)), n)
+	}
+	return sb.String()
+}
+
+// shortDuration prints three significant digits: 1.05ms rather than 1.048576ms
+func shortDuration(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "0"
+	case d < time.Microsecond:
+		return fmt.Sprintf("%dns", d.Nanoseconds())
+	case d < time.Millisecond:
+		return fmt.Sprintf("%.3gµs", float64(d)/float64(time.Microsecond))
+	case d < time.Second:
+		return fmt.Sprintf("%.3gms", float64(d)/float64(time.Millisecond))
+	default:
+		return fmt.Sprintf("%.3gs", d.Seconds())
+	}
+}
+
+// LockStats records how long callers waited for a lock and how long they held it
+type LockStats struct {
+	acquisitions atomic.Uint64
+	contended    atomic.Uint64 // Acquisitions that found the lock taken
+	wait         lockHistogram
+	hold         lockHistogram
+}
+
+// ObserveAcquire records one successful acquisition
+func (s *LockStats) ObserveAcquire(wait time.Duration, contended bool) {
+	s.acquisitions.Add(1)
+	if contended {
+		s.contended.Add(1)
+	}
+	s.wait.record(wait)
+}
+
+// ObserveRelease records how long the lock was held
+func (s *LockStats) ObserveRelease(hold time.Duration) {
+	s.hold.record(hold)
+}
+
+// Summary is the one-line report every map experiment prints
+func (s *LockStats) Summary() string {
+	n := s.acquisitions.Load()
+	if n == 0 {
+		return "🔒 Lock: never acquired"
+	}
+	return fmt.Sprintf("🔒 Lock: %d acquisitions, %.1f%% contended | wait mean %s p99 ≤%s max %s | hold mean %s p99 ≤%s max %s",
+		n, 100*float64(s.contended.Load())/float64(n),
+		shortDuration(s.wait.mean()), shortDuration(s.wait.percentile(0.99)), shortDuration(time.Duration(s.wait.max.Load())),
+		shortDuration(s.hold.mean()), shortDuration(s.hold.percentile(0.99)), shortDuration(time.Duration(s.hold.max.Load())))
+}
+
+// Histograms draws the wait and hold distributions
+func (s *LockStats) Histograms() string {
+	return "⏳ Wait times:\n" + s.wait.bars("   ") + "✊ Hold times:\n" + s.hold.bars("   ")
+}
+
+// lockTracer watches every acquisition and release of the Instrumented
+// mutexes here and the Ctx ones in ctxlock.go. It stays nil unless
+// lockdebug.go is compiled in.
+var lockTracer interface {
+	acquiring(lock any) // About to block on lock
+	acquired(lock any)
+	released(lock any)
+}
+
+func traceAcquiring(lock any) {
+	if lockTracer != nil {
+		lockTracer.acquiring(lock)
+	}
+}
+
+func traceAcquired(lock any) {
+	if lockTracer != nil {
+		lockTracer.acquired(lock)
+	}
+}
+
+func traceReleased(lock any) {
+	if lockTracer != nil {
+		lockTracer.released(lock)
+	}
+}
+
+// InstrumentedMutex is a sync.Mutex that keeps LockStats. It drops into any
+// mu field - Lock/Unlock/TryLock behave exactly like sync.Mutex.
+type InstrumentedMutex struct {
+	mu         sync.Mutex
+	acquiredAt time.Time // Only touched by the holder
+	stats      LockStats
+}
+
+// Lock acquires the mutex, timing the wait if someone else has it
+func (m *InstrumentedMutex) Lock() {
+	traceAcquiring(m)
+	if m.mu.TryLock() {
+		m.acquiredAt = time.Now()
+		m.stats.ObserveAcquire(0, false)
+		traceAcquired(m)
+		return
+	}
+	start := time.Now()
+	m.mu.Lock()
+	m.acquiredAt = time.Now()
+	m.stats.ObserveAcquire(m.acquiredAt.Sub(start), true)
+	traceAcquired(m)
+}
+
+// TryLock acquires the mutex only if it is free
+func (m *InstrumentedMutex) TryLock() bool {
+	if !m.mu.TryLock() {
+		return false
+	}
+	m.acquiredAt = time.Now()
+	m.stats.ObserveAcquire(0, false)
+	traceAcquired(m)
+	return true
+}
+
+// Unlock releases the mutex and records the hold time
+func (m *InstrumentedMutex) Unlock() {
+	traceReleased(m)
+	hold := time.Since(m.acquiredAt)
+	m.mu.Unlock()
+	m.stats.ObserveRelease(hold)
+}
+
+// Stats returns the live statistics
+func (m *InstrumentedMutex) Stats() *LockStats {
+	return &m.stats
+}
+
+// InstrumentedRWMutex is a sync.RWMutex that keeps separate LockStats for
+// writers and readers. Readers share the lock, so the read hold time is
+// the span from the first reader in to the last reader out.
+type InstrumentedRWMutex struct {
+	mu         sync.RWMutex
+	acquiredAt time.Time     // Writer's acquisition, only touched by the writer
+	readState  atomic.Uint64 // Reader count and group start, see readerIn
+	writeStats LockStats
+	readStats  LockStats
+}
+
+// readState keeps the reader count in its low readerBits and the time the
+// reader group began above them, so one CAS moves both. Kept apart, the last
+// reader out could read a start time the next group's first reader had just
+// stored and log a hold of nearly nothing.
+const (
+	readerBits = 20 // Up to a million readers at once
+	readerMask = 1<<readerBits - 1
+)
+
+// lockEpoch anchors the group start times in readState
+var lockEpoch = time.Now()
+
+// readClock is nanoseconds since lockEpoch, shifted up into readState's time
+// field. The top bits fall off, so it wraps every 2^44ns (~4.9 hours), but
+// subtracting two readings still gives any hold shorter than that.
+func readClock() uint64 {
+	return uint64(time.Since(lockEpoch)) << readerBits
+}
+
+// Lock acquires the write lock
+func (rw *InstrumentedRWMutex) Lock() {
+	traceAcquiring(rw)
+	if rw.mu.TryLock() {
+		rw.acquiredAt = time.Now()
+		rw.writeStats.ObserveAcquire(0, false)
+		traceAcquired(rw)
+		return
+	}
+	start := time.Now()
+	rw.mu.Lock()
+	rw.acquiredAt = time.Now()
+	rw.writeStats.ObserveAcquire(rw.acquiredAt.Sub(start), true)
+	traceAcquired(rw)
+}
+
+// Unlock releases the write lock
+func (rw *InstrumentedRWMutex) Unlock() {
+	traceReleased(rw)
+	hold := time.Since(rw.acquiredAt)
+	rw.mu.Unlock()
+	rw.writeStats.ObserveRelease(hold)
+}
+
+// RLock acquires a read lock
+func (rw *InstrumentedRWMutex) RLock() {
+	traceAcquiring(rw)
+	if rw.mu.TryRLock() {
+		rw.readerIn()
+		rw.readStats.ObserveAcquire(0, false)
+		traceAcquired(rw)
+		return
+	}
+	start := time.Now()
+	rw.mu.RLock()
+	rw.readerIn()
+	rw.readStats.ObserveAcquire(time.Since(start), true)
+	traceAcquired(rw)
+}
+
+// RUnlock releases a read lock
+func (rw *InstrumentedRWMutex) RUnlock() {
+	traceReleased(rw)
+	rw.readerOut()
+	rw.mu.RUnlock()
+}
+
+// readerIn counts a reader in, starting the group clock if it's the first
+func (rw *InstrumentedRWMutex) readerIn() {
+	for {
+		old := rw.readState.Load()
+		next := old + 1
+		if old&readerMask == 0 {
+			next = readClock() | 1
+		}
+		if rw.readState.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// readerOut counts a reader out; the last one records the group's hold
+func (rw *InstrumentedRWMutex) readerOut() {
+	for {
+		old := rw.readState.Load()
+		if old&readerMask > 1 {
+			if rw.readState.CompareAndSwap(old, old-1) {
+				return
+			}
+			continue
+		}
+		if rw.readState.CompareAndSwap(old, 0) {
+			since := old &^ readerMask
+			rw.readStats.ObserveRelease(time.Duration((readClock() - since) >> readerBits))
+			return
+		}
+	}
+}
+
+// TryLock acquires the write lock only if it is free
+func (rw *InstrumentedRWMutex) TryLock() bool {
+	if !rw.mu.TryLock() {
+		return false
+	}
+	rw.acquiredAt = time.Now()
+	rw.writeStats.ObserveAcquire(0, false)
+	traceAcquired(rw)
+	return true
+}
+
+// TryRLock acquires a read lock only if no writer has it
+func (rw *InstrumentedRWMutex) TryRLock() bool {
+	if !rw.mu.TryRLock() {
+		return false
+	}
+	rw.readerIn()
+	rw.readStats.ObserveAcquire(0, false)
+	traceAcquired(rw)
+	return true
+}
+
+// WriteStats returns the live writer statistics
+func (rw *InstrumentedRWMutex) WriteStats() *LockStats {
+	return &rw.writeStats
+}
+
+// ReadStats returns the live reader statistics
+func (rw *InstrumentedRWMutex) ReadStats() *LockStats {
+	return &rw.readStats
+}
@@ -9,13 +9,13 @@ import (
 
 // Regular Mutex Map - EXCLUSIVE access only
 type SafeMap struct {
-	mu sync.Mutex
+	mu InstrumentedMutex // A sync.Mutex that keeps a diary
 	m  map[int]int
 }
 
 // RWMutex Map - Multiple readers OR one writer
 type RWMap struct {
-	mu sync.RWMutex // The dual-nature spell!
+	mu InstrumentedRWMutex // The dual-nature spell!
 	m  map[int]int
 }
 
@@ -36,6 +36,7 @@ func main() {
 
 func testRegularMutex(safeMap *SafeMap) {
 	var wg sync.WaitGroup
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// Writers - like Ethel Cain recording vocals
@@ -67,6 +68,7 @@ func testRegularMutex(safeMap *SafeMap) {
 
 	wg.Wait()
 	writeTime := time.Since(startTime)
+	sched := probe.Stop()
 
 	safeMap.mu.Lock()
 	finalLen := len(safeMap.m)
@@ -74,10 +76,15 @@ func testRegularMutex(safeMap *SafeMap) {
 
 	fmt.Printf("📊 Final map size: %d\n", finalLen)
 	fmt.Printf("⏱️ Total time: %v\n", writeTime)
+	fmt.Println(safeMap.mu.Stats().Summary())
+	fmt.Print(safeMap.mu.Stats().Histograms())
+	fmt.Println(sched.Summary())
+	fmt.Print(sched.Histogram())
 }
 
 func testRWMutex(rwMap *RWMap) {
 	var wg sync.WaitGroup
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// Writers - like Sexyy Red dropping exclusive content
@@ -109,6 +116,7 @@ func testRWMutex(rwMap *RWMap) {
 
 	wg.Wait()
 	writeTime := time.Since(startTime)
+	sched := probe.Stop()
 
 	rwMap.mu.RLock()
 	finalLen := len(rwMap.m)
@@ -116,4 +124,10 @@ func testRWMutex(rwMap *RWMap) {
 
 	fmt.Printf("📊 Final map size: %d\n", finalLen)
 	fmt.Printf("⏱️ Total time: %v\n", writeTime)
+	fmt.Println("Writers: " + rwMap.mu.WriteStats().Summary())
+	fmt.Print(rwMap.mu.WriteStats().Histograms())
+	fmt.Println("Readers: " + rwMap.mu.ReadStats().Summary())
+	fmt.Print(rwMap.mu.ReadStats().Histograms())
+	fmt.Println(sched.Summary())
+	fmt.Print(sched.Histogram())
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// preemptMode is one way of sharing the CPU between spinners and the
+// latency-sensitive ping-pong
+type preemptMode struct {
+	name     string
+	spinners bool   // Run GOMAXPROCS tight loops alongside the ping-pong
+	gosched  bool   // Spinners yield every goschedEvery of work
+	godebug  string // Non-empty runs the trial in a child with this GODEBUG
+}
+
+var preemptModes = []preemptMode{
+	{"no spinners", false, false, ""},
+	{"spinners, async preemption", true, false, ""},
+	{"spinners + Gosched", true, true, ""},
+	{"spinners, asyncpreemptoff=1", true, false, "asyncpreemptoff=1"},
+	{"spinners + Gosched, asyncpreemptoff=1", true, true, "asyncpreemptoff=1"},
+}
+
+// goschedEvery is how much spinning a polite spinner does between yields
+const goschedEvery = 50 * time.Microsecond
+
+// sink keeps the compiler from throwing the spin loops away. Spinners sum
+// into a local and add it once, so they never race on it.
+var sink atomic.Uint64
+
+func main() {
+	mode := flag.Int("mode", -1, "internal: run only this mode's trial and print its row")
+	spinFor := flag.Duration("spin", 500*time.Millisecond, "how long each spinner burns CPU")
+	flag.Parse()
+
+	itersPerMicro := calibrateSpin()
+
+	if *mode >= 0 {
+		printPreemptRow(preemptModes[*mode], runPreemptTrial(preemptModes[*mode], *spinFor, itersPerMicro))
+		return
+	}
+
+	fmt.Println("🌀 COOPERATIVE vs PREEMPTIVE POSSESSION 🌀")
+	fmt.Println(strings.Repeat("⚡", 30))
+	fmt.Printf("\nGOMAXPROCS=%d spinners burn %v each in a loop with no function calls,\n", runtime.GOMAXPROCS(0), *spinFor)
+	fmt.Println("while a ping-pong pair sleeps 1ms, wakes, and sends a round trip.")
+	fmt.Println("\"late\" is how far past its 1ms alarm the pinger woke up.")
+
+	self, err := os.Executable()
+	if err != nil {
+		panic("Lost our own body: " + err.Error())
+	}
+
+	fmt.Printf("\n%-38s %6s %10s %10s %10s %10s %10s\n",
+		"mode", "rounds", "late p50", "late p99", "late max", "rtt p99", "rtt max")
+	for i, m := range preemptModes {
+		if m.godebug == "" {
+			printPreemptRow(m, runPreemptTrial(m, *spinFor, itersPerMicro))
+			continue
+		}
+		// GODEBUG is read at start-up, so this trial needs a fresh process
+		cmd := exec.Command(self, "-mode", strconv.Itoa(i), "-spin", spinFor.String())
+		cmd.Env = append(os.Environ(), "GODEBUG="+m.godebug)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			panic("Child séance failed: " + err.Error())
+		}
+	}
+
+	fmt.Println("\n💀 Since Go 1.14 the runtime can interrupt a loop with a signal, so a spinner")
+	fmt.Println("can only hog a P for ~10ms. With asyncpreemptoff=1 a call-free loop is never")
+	fmt.Println("interrupted and the pinger waits for the whole spin - unless the spinner is")
+	fmt.Println("polite enough to Gosched, like Ethel Cain pausing mid-verse to let the choir in.")
+}
+
+type preemptResult struct {
+	rounds int
+	late   []time.Duration // Wake-up delay past each 1ms sleep
+	rtt    []time.Duration // Ping-pong round trip after waking
+}
+
+// runPreemptTrial runs the ping-pong for spinFor, with the mode's spinners
+// competing for every P
+func runPreemptTrial(m preemptMode, spinFor time.Duration, itersPerMicro float64) preemptResult {
+	ping := make(chan struct{})
+	pong := make(chan struct{})
+	spinnersDone := make(chan struct{})
+	pingerReady := make(chan struct{})
+	var res preemptResult
+	start := time.Now()
+
+	var latency sync.WaitGroup
+	latency.Add(2)
+
+	// The latency-sensitive pair
+	go func() {
+		defer latency.Done()
+		for range ping {
+			pong <- struct{}{}
+		}
+	}()
+	go func() {
+		defer latency.Done()
+		close(pingerReady)
+		for {
+			select {
+			case <-spinnersDone:
+				if time.Since(start) >= spinFor {
+					close(ping)
+					return
+				}
+			default:
+			}
+
+			alarm := time.Now().Add(time.Millisecond)
+			time.Sleep(time.Millisecond)
+			woke := time.Now()
+			ping <- struct{}{}
+			<-pong
+
+			res.late = append(res.late, woke.Sub(alarm))
+			res.rtt = append(res.rtt, time.Since(woke))
+		}
+	}()
+
+	// The pinger must be asleep on its first alarm before the hogs arrive,
+	// or with preemption off it would not even start until they finish
+	<-pingerReady
+
+	// The CPU hogs
+	var spinners sync.WaitGroup
+	if m.spinners {
+		total := int(itersPerMicro * float64(spinFor.Microseconds()))
+		chunk := int(itersPerMicro * float64(goschedEvery.Microseconds()))
+		for s := 0; s < runtime.GOMAXPROCS(0); s++ {
+			spinners.Add(1)
+			go func() {
+				defer spinners.Done()
+				if !m.gosched {
+					sink.Add(spin(total))
+					return
+				}
+				var sum uint64
+				for done := 0; done < total; done += chunk {
+					sum += spin(chunk)
+					runtime.Gosched()
+				}
+				sink.Add(sum)
+			}()
+		}
+	}
+	spinners.Wait()
+	close(spinnersDone)
+	latency.Wait()
+
+	res.rounds = len(res.late)
+	return res
+}
+
+// spin burns n iterations without a single function call, so the only way
+// to stop it early is an asynchronous preemption signal
+//
+//go:noinline
+func spin(n int) uint64 {
+	var x uint64
+	for i := 0; i < n; i++ {
+		x = x*6364136223846793005 + uint64(i)
+	}
+	return x
+}
+
+// calibrateSpin measures how many spin iterations fit in a microsecond
+func calibrateSpin() float64 {
+	const n = 20_000_000
+	start := time.Now()
+	sink.Add(spin(n))
+	return n / float64(time.Since(start).Microseconds())
+}
+
+func printPreemptRow(m preemptMode, r preemptResult) {
+	if len(r.late) == 0 {
+		// The spinners held the pinger off for the whole trial
+		fmt.Printf("%-38s %6d %10s %10s %10s %10s %10s\n", m.name, r.rounds, "-", "-", "-", "-", "-")
+		return
+	}
+	fmt.Printf("%-38s %6d %10s %10s %10s %10s %10s\n", m.name, r.rounds,
+		micros(percentile(r.late, 0.50)), micros(percentile(r.late, 0.99)), micros(slices.Max(r.late)),
+		micros(percentile(r.rtt, 0.99)), micros(slices.Max(r.rtt)))
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	return sorted[min(int(p*float64(len(sorted))), len(sorted)-1)]
+}
+
+func micros(d time.Duration) string {
+	return fmt.Sprintf("%.1fµs", float64(d)/float64(time.Microsecond))
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Queue is a bounded FIFO shared by producers and consumers
+type Queue[T any] interface {
+	Put(v T)        // Blocks while the queue is full; panics after Close
+	Get() (T, bool) // Blocks while empty; false once closed and drained
+	Close()
+}
+
+// CondQueue is a ring buffer guarded by a mutex, with sync.Cond for the waiting
+type CondQueue[T any] struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	buf      []T
+	head     int
+	count    int
+	closed   bool
+}
+
+func NewCondQueue[T any](capacity int) *CondQueue[T] {
+	q := &CondQueue[T]{buf: make([]T, capacity)}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *CondQueue[T]) Put(v T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.count == len(q.buf) && !q.closed {
+		q.notFull.Wait()
+	}
+	if q.closed {
+		panic("CondQueue: put on closed queue")
+	}
+	q.buf[(q.head+q.count)%len(q.buf)] = v
+	q.count++
+	q.notEmpty.Signal()
+}
+
+func (q *CondQueue[T]) Get() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.count == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	var v T
+	if q.count == 0 {
+		return v, false // Closed and drained
+	}
+	v = q.buf[q.head]
+	q.head = (q.head + 1) % len(q.buf)
+	q.count--
+	q.notFull.Signal()
+	return v, true
+}
+
+func (q *CondQueue[T]) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// ChanQueue is just a buffered channel wearing the Queue costume
+type ChanQueue[T any] struct {
+	ch chan T
+}
+
+func NewChanQueue[T any](capacity int) *ChanQueue[T] {
+	return &ChanQueue[T]{ch: make(chan T, capacity)}
+}
+
+func (q *ChanQueue[T]) Put(v T) { q.ch <- v }
+
+func (q *ChanQueue[T]) Get() (T, bool) {
+	v, ok := <-q.ch
+	return v, ok
+}
+
+func (q *ChanQueue[T]) Close() { close(q.ch) }
+
+// ringCell carries a sequence number that says whose turn it is
+type ringCell[T any] struct {
+	seq atomic.Uint64
+	val T
+}
+
+// RingQueue is Dmitry Vyukov's bounded lock-free MPMC queue. Nobody ever
+// holds a lock; blocked producers and consumers spin with runtime.Gosched.
+type RingQueue[T any] struct {
+	_      [64]byte // Keep the hot cursors on their own cache lines
+	enq    atomic.Uint64
+	_      [56]byte
+	deq    atomic.Uint64
+	_      [56]byte
+	cells  []ringCell[T]
+	mask   uint64
+	closed atomic.Bool
+}
+
+// NewRingQueue rounds capacity up to a power of two, and to at least 2 -
+// with a single cell the sequence numbers can't tell "full" from "free"
+func NewRingQueue[T any](capacity int) *RingQueue[T] {
+	n := 1 << bits.Len(uint(max(capacity, 2)-1))
+	q := &RingQueue[T]{cells: make([]ringCell[T], n), mask: uint64(n - 1)}
+	for i := range q.cells {
+		q.cells[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+// TryPut enqueues without blocking and reports whether there was room
+func (q *RingQueue[T]) TryPut(v T) bool {
+	pos := q.enq.Load()
+	for {
+		cell := &q.cells[pos&q.mask]
+		dif := int64(cell.seq.Load()) - int64(pos)
+		switch {
+		case dif == 0:
+			if q.enq.CompareAndSwap(pos, pos+1) {
+				cell.val = v
+				cell.seq.Store(pos + 1) // Hand the cell to consumers
+				return true
+			}
+			pos = q.enq.Load()
+		case dif < 0:
+			return false // Full - the consumer lap hasn't freed this cell yet
+		default:
+			pos = q.enq.Load() // Another producer got here first
+		}
+	}
+}
+
+// TryGet dequeues without blocking and reports whether anything was there
+func (q *RingQueue[T]) TryGet() (T, bool) {
+	pos := q.deq.Load()
+	for {
+		cell := &q.cells[pos&q.mask]
+		dif := int64(cell.seq.Load()) - int64(pos+1)
+		switch {
+		case dif == 0:
+			if q.deq.CompareAndSwap(pos, pos+1) {
+				v := cell.val
+				var zero T
+				cell.val = zero
+				cell.seq.Store(pos + q.mask + 1) // Hand the cell back for the next lap
+				return v, true
+			}
+			pos = q.deq.Load()
+		case dif < 0:
+			var zero T
+			return zero, false // Empty
+		default:
+			pos = q.deq.Load()
+		}
+	}
+}
+
+func (q *RingQueue[T]) Put(v T) {
+	for !q.TryPut(v) {
+		if q.closed.Load() {
+			panic("RingQueue: put on closed queue")
+		}
+		runtime.Gosched()
+	}
+}
+
+func (q *RingQueue[T]) Get() (T, bool) {
+	for {
+		if v, ok := q.TryGet(); ok {
+			return v, true
+		}
+		if q.closed.Load() {
+			// One last look - a Put may have landed just before Close
+			return q.TryGet()
+		}
+		runtime.Gosched()
+	}
+}
+
+func (q *RingQueue[T]) Close() { q.closed.Store(true) }
+
+type queueResult struct {
+	throughput float64 // Items per second
+	p50        time.Duration
+	p99        time.Duration
+	p999       time.Duration
+}
+
+func main() {
+	fmt.Println("🕯️ THE PRODUCER/CONSUMER SÉANCE 🕯️")
+	fmt.Println(strings.Repeat("⚡", 30))
+
+	const items = 100_000
+
+	queues := []struct {
+		name string
+		make func(capacity int) Queue[int64]
+	}{
+		{"sync.Cond", func(c int) Queue[int64] { return NewCondQueue[int64](c) }},
+		{"channel", func(c int) Queue[int64] { return NewChanQueue[int64](c) }},
+		{"lock-free ring", func(c int) Queue[int64] { return NewRingQueue[int64](c) }},
+	}
+	shapes := [][2]int{{1, 1}, {1, 8}, {8, 1}, {8, 8}, {32, 32}}
+	capacities := []int{1, 64, 1024}
+
+	fmt.Printf("\nGOMAXPROCS=%d, %d items per run, latency = enqueue to dequeue\n\n",
+		runtime.GOMAXPROCS(0), items)
+	fmt.Printf("%-15s %5s %5s %6s %12s %10s %10s %10s\n",
+		"queue", "prod", "cons", "cap", "items/sec", "p50", "p99", "p99.9")
+
+	for _, shape := range shapes {
+		for _, capacity := range capacities {
+			for _, q := range queues {
+				r := runProducerConsumer(q.make(capacity), shape[0], shape[1], items)
+				fmt.Printf("%-15s %5d %5d %6d %12.0f %10v %10v %10v\n",
+					q.name, shape[0], shape[1], capacity, r.throughput, r.p50, r.p99, r.p999)
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("💀 Capacity 1 turns every queue into a handoff - each item waits for a partner,")
+	fmt.Println("like FKA twigs refusing to start the next move until the last dancer lands.")
+}
+
+// runProducerConsumer pushes items through q and times each one's trip
+func runProducerConsumer(q Queue[int64], producers, consumers, items int) queueResult {
+	var producerWG, consumerWG sync.WaitGroup
+	latencies := make([][]time.Duration, consumers)
+	epoch := time.Now()
+
+	start := time.Now()
+	for c := 0; c < consumers; c++ {
+		consumerWG.Add(1)
+		go func(id int) {
+			defer consumerWG.Done()
+			mine := make([]time.Duration, 0, items/consumers+1)
+			for {
+				sent, ok := q.Get()
+				if !ok {
+					break
+				}
+				mine = append(mine, time.Since(epoch)-time.Duration(sent))
+			}
+			latencies[id] = mine
+		}(c)
+	}
+
+	for p := 0; p < producers; p++ {
+		producerWG.Add(1)
+		go func(id int) {
+			defer producerWG.Done()
+			// Spread the remainder so exactly items get produced
+			n := items / producers
+			if id < items%producers {
+				n++
+			}
+			for i := 0; i < n; i++ {
+				q.Put(int64(time.Since(epoch)))
+			}
+		}(p)
+	}
+
+	producerWG.Wait()
+	q.Close()
+	consumerWG.Wait()
+	elapsed := time.Since(start)
+
+	all := slices.Concat(latencies...)
+	if len(all) != items {
+		panic(fmt.Sprintf("queue lost souls: got %d, want %d", len(all), items))
+	}
+	slices.Sort(all)
+
+	return queueResult{
+		throughput: float64(items) / elapsed.Seconds(),
+		p50:        all[len(all)*50/100],
+		p99:        all[len(all)*99/100],
+		p999:       all[len(all)*999/1000],
+	}
+}
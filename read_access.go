@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// readStrategy is one way to get every line of a file into memory. run
+// returns how many lines it saw so the strategies can be checked against
+// each other.
+type readStrategy struct {
+	name string
+	run  func(path string) (int, error)
+}
+
+var readStrategies = []readStrategy{
+	{"unbuffered Read, small chunks", readChunks},
+	{"bufio.Reader.ReadString", readStrings},
+	{"bufio.Scanner", scanLines},
+	{"os.ReadFile", readWhole},
+	{"mmap", mmapLines},
+}
+
+// chunkBytes is how much each unbuffered Read asks for
+var chunkBytes = 64
+
+func main() {
+	files := flag.String("files", "buffered_magic.txt,unbuffered_horror.txt", "comma-separated files to read (file_access.go writes these)")
+	flag.IntVar(&chunkBytes, "chunk", 64, "bytes per unbuffered Read")
+	rounds := flag.Int("rounds", 3, "runs per strategy and cache state")
+	flag.Parse()
+	if chunkBytes < 1 {
+		fmt.Fprintln(os.Stderr, "-chunk must be at least 1")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *rounds < 1 {
+		fmt.Fprintln(os.Stderr, "-rounds must be at least 1")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fmt.Println("📖 READING THE CURSED TOMES BACK 📖")
+	fmt.Println(strings.Repeat("🩸", 25))
+	fmt.Printf("\nEvery strategy counts lines, mean of %d runs. Cold runs drop the file from the\n", *rounds)
+	fmt.Println("page cache first with posix_fadvise(DONTNEED); warm runs read it once beforehand.")
+	fmt.Println("read(2) and disk bytes come from /proc/self/io (reading it costs ~2 read(2)s of")
+	fmt.Println("its own), major faults from getrusage.")
+
+	for _, path := range strings.Split(*files, ",") {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Printf("\n👻 %s is missing - run file_access.go first to write it\n", path)
+			continue
+		}
+
+		fmt.Printf("\n📜 %s, %s\n", path, byteCount(info.Size()))
+		caches := []bool{true, false}
+		before := residentPercent(path)
+		if err := dropCache(path); err != nil {
+			fmt.Printf("⚠️ Can't drop the page cache here (%v) - warm rows only\n", err)
+			caches = caches[1:]
+		} else {
+			after := residentPercent(path)
+			fmt.Printf("🧊 DONTNEED took residency from %.0f%% to %.0f%%", before, after)
+			if after > 10 {
+				fmt.Print(" - this filesystem won't let go, so cold is not very cold")
+			}
+			fmt.Println()
+		}
+
+		fmt.Printf("\n%-30s %-5s %11s %9s %9s %11s %7s %8s\n",
+			"strategy", "cache", "mean time", "MB/s", "read(2)", "disk read", "majflt", "lines")
+		for _, s := range readStrategies {
+			for _, cold := range caches {
+				printReadRow(s, path, info.Size(), cold, *rounds)
+			}
+		}
+	}
+
+	fmt.Println("\n💀 Tiny unbuffered Reads pay a kernel crossing per chunk, just like the writes")
+	fmt.Println("did. bufio and Scanner turn that into one read(2) per 4KB buffer, ReadFile asks")
+	fmt.Println("for the whole file in a couple of calls, and mmap makes no read(2) at all - it")
+	fmt.Println("pays in page faults instead, and readahead keeps most of those minor even when")
+	fmt.Println("the cache starts cold. Like Mitski reading her diary aloud: one page at a time")
+	fmt.Println("is intimate, but the whole book at once gets you to the ending.")
+}
+
+// readRun is the cost of one strategy reading one file once
+type readRun struct {
+	elapsed   time.Duration
+	lines     int
+	kernel    readIO
+	majFaults int64
+}
+
+func printReadRow(s readStrategy, path string, size int64, cold bool, rounds int) {
+	cache := "warm"
+	if cold {
+		cache = "cold"
+	} else if _, err := s.run(path); err != nil { // Warm-up read
+		panic(s.name + " failed: " + err.Error())
+	}
+
+	var total readRun
+	for r := 0; r < rounds; r++ {
+		if cold {
+			dropCache(path)
+		}
+		run := measureRead(s, path)
+		total.elapsed += run.elapsed
+		total.kernel = total.kernel.add(run.kernel)
+		total.majFaults += run.majFaults
+		total.lines = run.lines
+	}
+	mean := total.elapsed / time.Duration(rounds)
+	n := int64(rounds)
+
+	fmt.Printf("%-30s %-5s %11v %9.0f %9d %11s %7d %8d\n", s.name, cache, mean.Round(time.Microsecond),
+		float64(size)/(1<<20)/mean.Seconds(), total.kernel.syscr/n, byteCount(total.kernel.readBytes/n),
+		total.majFaults/n, total.lines)
+}
+
+func measureRead(s readStrategy, path string) readRun {
+	var usageBefore, usageAfter syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usageBefore)
+	ioBefore := readReadIO()
+
+	start := time.Now()
+	lines, err := s.run(path)
+	elapsed := time.Since(start)
+	if err != nil {
+		panic(s.name + " failed: " + err.Error())
+	}
+
+	kernel := readReadIO().sub(ioBefore)
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usageAfter)
+	return readRun{
+		elapsed:   elapsed,
+		lines:     lines,
+		kernel:    kernel,
+		majFaults: usageAfter.Majflt - usageBefore.Majflt,
+	}
+}
+
+// readChunks is the read-side twin of testUnbuffered: a read(2) per chunk
+func readChunks(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	chunk := make([]byte, chunkBytes)
+	lines := 0
+	for {
+		n, err := file.Read(chunk)
+		lines += bytes.Count(chunk[:n], []byte{'\n'})
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// readStrings allocates a fresh string for every line
+func readStrings(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	lines := 0
+	for {
+		_, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		lines++
+	}
+}
+
+// scanLines hands out slices of its own buffer - no allocation per line
+func scanLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+	}
+	return lines, scanner.Err()
+}
+
+// readWhole pulls the entire file into one slice
+func readWhole(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	return bytes.Count(data, []byte{'\n'}), err
+}
+
+// mmapLines maps the file and walks it in place - the page cache is the
+// buffer, and a page fault replaces every read(2)
+func mmapLines(path string) (int, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	defer unmap()
+	return bytes.Count(data, []byte{'\n'}), nil
+}
+
+// mapFile maps path read-only; an empty file maps to nil
+func mapFile(path string) ([]byte, func() error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close() // The mapping outlives the fd
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return nil, nil, err
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
+
+// residentPercent asks mincore how much of path is in the page cache. Just
+// mapping the file doesn't fault anything in.
+func residentPercent(path string) float64 {
+	data, unmap, err := mapFile(path)
+	if err != nil || data == nil {
+		return 0
+	}
+	defer unmap()
+
+	pageSize := os.Getpagesize()
+	vec := make([]byte, (len(data)+pageSize-1)/pageSize)
+	_, _, errno := syscall.Syscall(syscall.SYS_MINCORE,
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&vec[0])))
+	if errno != 0 {
+		return 0
+	}
+	resident := 0
+	for _, v := range vec {
+		resident += int(v & 1)
+	}
+	return 100 * float64(resident) / float64(len(vec))
+}
+
+// readIO is the read half of /proc/self/io. rchar is what read(2) handed
+// back; read_bytes is what actually came off the block device, which is
+// where cold and warm part ways.
+type readIO struct {
+	syscr     int64
+	rchar     int64
+	readBytes int64
+}
+
+// readReadIO returns zeros where /proc/self/io doesn't exist
+func readReadIO() readIO {
+	var ioStats readIO
+	data, err := os.ReadFile("/proc/self/io")
+	if err != nil {
+		return ioStats
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch key {
+		case "syscr":
+			ioStats.syscr = n
+		case "rchar":
+			ioStats.rchar = n
+		case "read_bytes":
+			ioStats.readBytes = n
+		}
+	}
+	return ioStats
+}
+
+func (a readIO) sub(b readIO) readIO {
+	return readIO{a.syscr - b.syscr, a.rchar - b.rchar, a.readBytes - b.readBytes}
+}
+
+func (a readIO) add(b readIO) readIO {
+	return readIO{a.syscr + b.syscr, a.rchar + b.rchar, a.readBytes + b.readBytes}
+}
+
+func byteCount(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
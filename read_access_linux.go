@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const posixFadvDontneed = 4 // POSIX_FADV_DONTNEED
+
+// dropCache writes back anything dirty, then asks the kernel to forget the
+// file's pages. DONTNEED skips dirty pages, hence the fdatasync first.
+func dropCache(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fd := int(file.Fd())
+	if err := syscall.Fdatasync(fd); err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, uintptr(fd), 0, 0, posixFadvDontneed, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package main
+
+import "errors"
+
+// dropCache has no posix_fadvise to call here, so main skips the cold rows
+func dropCache(path string) error {
+	return errors.ErrUnsupported
+}
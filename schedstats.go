@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"time"
+)
+
+// The runtime/metrics every experiment samples before and after a run
+const (
+	schedLatencyMetric = "/sched/latencies:seconds"       // Runnable -> running delay
+	goroutinesMetric   = "/sched/goroutines:goroutines"   // Live goroutines
+	mutexWaitMetric    = "/sync/mutex/wait/total:seconds" // Time blocked on sync.Mutex/RWMutex
+)
+
+// SchedProbe remembers the scheduler's counters at the start of a run
+type SchedProbe struct {
+	start   time.Time
+	samples []metrics.Sample
+}
+
+// StartSchedProbe samples the scheduler metrics right now
+func StartSchedProbe() *SchedProbe {
+	p := &SchedProbe{samples: newSchedSamples()}
+	metrics.Read(p.samples)
+	p.start = time.Now()
+	return p
+}
+
+// Stop samples again and returns what the scheduler did in between
+func (p *SchedProbe) Stop() *SchedStats {
+	wall := time.Since(p.start)
+	after := newSchedSamples()
+	metrics.Read(after)
+
+	s := &SchedStats{
+		Wall:             wall,
+		GoroutinesBefore: p.samples[1].Value.Uint64(),
+		GoroutinesAfter:  after[1].Value.Uint64(),
+		MutexWait:        secondsToDuration(after[2].Value.Float64() - p.samples[2].Value.Float64()),
+	}
+
+	// Bucket counts only grow, so the run's own histogram is the difference
+	before, now := p.samples[0].Value.Float64Histogram(), after[0].Value.Float64Histogram()
+	for i, n := range now.Counts {
+		lower := max(now.Buckets[i], 0) // The first edge may be -Inf
+		s.delay.recordN(secondsToDuration(lower), n-before.Counts[i])
+	}
+	return s
+}
+
+func newSchedSamples() []metrics.Sample {
+	return []metrics.Sample{
+		{Name: schedLatencyMetric},
+		{Name: goroutinesMetric},
+		{Name: mutexWaitMetric},
+	}
+}
+
+func secondsToDuration(sec float64) time.Duration {
+	if math.IsInf(sec, 0) || math.IsNaN(sec) {
+		return 0
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+// SchedStats is the scheduler's side of one run. The runtime only times a
+// sample of goroutine wake-ups, so the delay counts are smaller than the
+// real number of switches.
+type SchedStats struct {
+	Wall             time.Duration
+	GoroutinesBefore uint64
+	GoroutinesAfter  uint64
+	MutexWait        time.Duration // Summed across every blocked goroutine
+	delay            lockHistogram // How long runnable goroutines waited for a P
+}
+
+// Summary is the one-line report printed next to each run's wall time
+func (s *SchedStats) Summary() string {
+	return fmt.Sprintf("🗓️ Sched: wall %s | runnable delay p50 ≤%s p99 ≤%s max ≤%s (%d sampled) | goroutines %d→%d | mutex wait %s",
+		shortDuration(s.Wall),
+		shortDuration(s.delay.percentile(0.50)), shortDuration(s.delay.percentile(0.99)), shortDuration(s.delay.percentile(1)),
+		s.delay.count.Load(), s.GoroutinesBefore, s.GoroutinesAfter, shortDuration(s.MutexWait))
+}
+
+// Histogram draws the scheduling delay distribution
+func (s *SchedStats) Histogram() string {
+	if s.delay.count.Load() == 0 {
+		return "⏳ Scheduling delay: no samples\n"
+	}
+	return "⏳ Scheduling delay:\n" + s.delay.bars("   ")
+}
+
+// DelayPercentile is the upper edge of the bucket holding the p-th delay
+func (s *SchedStats) DelayPercentile(p float64) time.Duration {
+	return s.delay.percentile(p)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// counterSlotSize covers one 64-byte line plus the adjacent-line prefetcher
+// on x86, and a full 128-byte line on Apple silicon
+const counterSlotSize = 128
+
+// counterSlot is one stripe of a ShardedCounter, alone on its cache line
+type counterSlot struct {
+	n atomic.Uint64
+	_ [counterSlotSize - 8]byte
+}
+
+// ShardedCounter spreads increments over padded slots so writers stop
+// fighting over a single cache line
+type ShardedCounter struct {
+	slots []counterSlot
+	mask  uint32
+}
+
+// NewShardedCounter creates a counter with at least the given number of
+// slots, rounded up to a power of two. shards <= 0 means one per P.
+func NewShardedCounter(shards int) *ShardedCounter {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	n := 1 << bits.Len(uint(shards-1))
+	return &ShardedCounter{
+		slots: make([]counterSlot, n),
+		mask:  uint32(n - 1),
+	}
+}
+
+// Add increments a random slot. The global math/rand/v2 source is per-thread
+// inside the runtime, so picking one is cheap and uncontended, but nothing ties
+// a slot to a CPU: two goroutines can land on the same shard, and one goroutine
+// hops between shards from call to call. Use AddStripe for a stable owner.
+func (c *ShardedCounter) Add(delta uint64) {
+	c.slots[rand.Uint32()&c.mask].n.Add(delta)
+}
+
+// AddStripe increments the slot owned by hint (a worker ID, say)
+func (c *ShardedCounter) AddStripe(hint int, delta uint64) {
+	c.slots[uint32(hint)&c.mask].n.Add(delta)
+}
+
+// Load sums the slots without stopping writers. With adds in flight the
+// result lies somewhere between the values at the start and end of the call.
+func (c *ShardedCounter) Load() uint64 {
+	var total uint64
+	for i := range c.slots {
+		total += c.slots[i].n.Load()
+	}
+	return total
+}
+
+// maxExactSweeps bounds LoadExact. Writers that never pause long enough for
+// two matching sweeps would otherwise keep it spinning forever.
+const maxExactSweeps = 100
+
+// LoadExact returns a value the counter actually held at one instant.
+// Slots only grow, so two identical sweeps in a row mean nothing changed
+// between them. If writers keep the sweeps apart for maxExactSweeps tries it
+// gives up and returns the last sweep with ok false, which is only as good
+// as Load.
+func (c *ShardedCounter) LoadExact() (n uint64, ok bool) {
+	prev := c.Load()
+	for i := 0; i < maxExactSweeps; i++ {
+		cur := c.Load()
+		if cur == prev {
+			return cur, true
+		}
+		prev = cur
+	}
+	return prev, false
+}
+
+// benchCounter is what every contender in the séance must implement
+type benchCounter interface {
+	Inc(worker int)
+	Total() uint64
+	Close()
+}
+
+type atomicCounter struct{ n atomic.Uint64 }
+
+func (c *atomicCounter) Inc(int)       { c.n.Add(1) }
+func (c *atomicCounter) Total() uint64 { return c.n.Load() }
+func (c *atomicCounter) Close()        {}
+
+type mutexCounter struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+func (c *mutexCounter) Inc(int) {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+}
+
+func (c *mutexCounter) Total() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+func (c *mutexCounter) Close() {}
+
+// chanCounter is owned by a single goroutine - nobody else touches n
+type chanCounter struct {
+	inc  chan uint64
+	read chan chan uint64
+	done chan struct{}
+}
+
+func newChanCounter() *chanCounter {
+	c := &chanCounter{
+		inc:  make(chan uint64, 1024),
+		read: make(chan chan uint64),
+		done: make(chan struct{}),
+	}
+	go func() {
+		var n uint64
+		for {
+			select {
+			case d := <-c.inc:
+				n += d
+			case reply := <-c.read:
+				// Drain pending increments so the answer is exact
+				for len(c.inc) > 0 {
+					n += <-c.inc
+				}
+				reply <- n
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func (c *chanCounter) Inc(int) { c.inc <- 1 }
+
+func (c *chanCounter) Total() uint64 {
+	reply := make(chan uint64)
+	c.read <- reply
+	return <-reply
+}
+
+func (c *chanCounter) Close() { close(c.done) }
+
+// exactTotal is only called once the workers are done, so the sweeps settle
+// at once; running out of them means something is still writing
+func (c *ShardedCounter) exactTotal() uint64 {
+	n, ok := c.LoadExact()
+	if !ok {
+		panic("sharded counter still changing after the workers finished")
+	}
+	return n
+}
+
+type randomShardCounter struct{ *ShardedCounter }
+
+func (c randomShardCounter) Inc(int)       { c.Add(1) }
+func (c randomShardCounter) Total() uint64 { return c.exactTotal() }
+func (c randomShardCounter) Close()        {}
+
+type stripedShardCounter struct{ *ShardedCounter }
+
+func (c stripedShardCounter) Inc(worker int) { c.AddStripe(worker, 1) }
+func (c stripedShardCounter) Total() uint64  { return c.exactTotal() }
+func (c stripedShardCounter) Close()         {}
+
+type contender struct {
+	name string
+	make func() benchCounter
+}
+
+func main() {
+	fmt.Println("🩸 THE SHARDED COUNTER TRIALS 🩸")
+	fmt.Println(strings.Repeat("⚡", 30))
+
+	const totalOps = 1 << 20 // Split evenly across the summoned goroutines
+	const runs = 3
+
+	contenders := []contender{
+		{"atomic", func() benchCounter { return &atomicCounter{} }},
+		{"mutex", func() benchCounter { return &mutexCounter{} }},
+		{"channel", func() benchCounter { return newChanCounter() }},
+		{"sharded", func() benchCounter { return randomShardCounter{NewShardedCounter(0)} }},
+		{"striped", func() benchCounter { return stripedShardCounter{NewShardedCounter(0)} }},
+	}
+	goroutineCounts := []int{1, 2, 4, 8, 16, 32, 64, 128, 256}
+
+	fmt.Printf("GOMAXPROCS=%d, %d increments per run, mean of %d runs (ns/op)\n\n",
+		runtime.GOMAXPROCS(0), totalOps, runs)
+
+	fmt.Printf("%-10s", "goroutines")
+	for _, c := range contenders {
+		fmt.Printf("%12s", c.name)
+	}
+	fmt.Println()
+
+	var bottleneck int
+	for _, g := range goroutineCounts {
+		fmt.Printf("%-10d", g)
+		perOp := make(map[string]float64)
+		for _, c := range contenders {
+			var total time.Duration
+			for run := 0; run < runs; run++ {
+				total += runCounterTrial(c.make(), g, totalOps/g)
+			}
+			ns := float64(total.Nanoseconds()) / float64(runs*totalOps)
+			perOp[c.name] = ns
+			fmt.Printf("%12.2f", ns)
+		}
+		fmt.Println()
+
+		// The single atomic is the bottleneck once sharding wins by 2x
+		if bottleneck == 0 && perOp["atomic"] > 2*perOp["sharded"] {
+			bottleneck = g
+		}
+	}
+
+	if bottleneck > 0 {
+		fmt.Printf("\n💀 The lone atomic.Uint64 chokes at %d goroutines - its cache line is the cursed object everyone fights over\n", bottleneck)
+	} else {
+		fmt.Println("\n✨ The lone atomic.Uint64 held on at every goroutine count on this machine")
+	}
+}
+
+// runCounterTrial hammers one counter from g goroutines and checks the final total
+func runCounterTrial(c benchCounter, g, opsPerGoroutine int) time.Duration {
+	defer c.Close()
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < g; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < opsPerGoroutine; i++ {
+				c.Inc(worker)
+			}
+		}(w)
+	}
+	wg.Wait()
+	duration := time.Since(start)
+
+	if want := uint64(g * opsPerGoroutine); c.Total() != want {
+		panic(fmt.Sprintf("counter lost increments: got %d, want %d", c.Total(), want))
+	}
+	return duration
+}
@@ -10,13 +10,13 @@ import (
 
 // Regular Mutex Map
 type SafeMap struct {
-	mu sync.Mutex
+	mu InstrumentedMutex
 	m  map[int]int
 }
 
 // RWMutex Map
 type RWMap struct {
-	mu sync.RWMutex
+	mu InstrumentedRWMutex
 	m  map[int]int
 }
 
@@ -59,6 +59,7 @@ func main() {
 
 func testRegularMutex(safeMap *SafeMap) time.Duration {
 	var wg sync.WaitGroup
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// 50 writers
@@ -76,17 +77,21 @@ func testRegularMutex(safeMap *SafeMap) time.Duration {
 
 	wg.Wait()
 	duration := time.Since(startTime)
+	sched := probe.Stop()
 
 	safeMap.mu.Lock()
 	finalLen := len(safeMap.m)
 	safeMap.mu.Unlock()
 
 	fmt.Printf("📊 Map size: %d | ⏱️ Time: %v\n", finalLen, duration)
+	fmt.Println(safeMap.mu.Stats().Summary())
+	fmt.Println(sched.Summary())
 	return duration
 }
 
 func testRWMutex(rwMap *RWMap) time.Duration {
 	var wg sync.WaitGroup
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// 50 writers
@@ -104,18 +109,22 @@ func testRWMutex(rwMap *RWMap) time.Duration {
 
 	wg.Wait()
 	duration := time.Since(startTime)
+	sched := probe.Stop()
 
 	rwMap.mu.RLock()
 	finalLen := len(rwMap.m)
 	rwMap.mu.RUnlock()
 
 	fmt.Printf("📊 Map size: %d | ⏱️ Time: %v\n", finalLen, duration)
+	fmt.Println(rwMap.mu.WriteStats().Summary())
+	fmt.Println(sched.Summary())
 	return duration
 }
 
 func testSyncMap() time.Duration {
 	var m sync.Map
 	var wg sync.WaitGroup
+	probe := StartSchedProbe()
 	startTime := time.Now()
 
 	// 50 writers - like 50 witches casting spells simultaneously
@@ -131,6 +140,7 @@ func testSyncMap() time.Duration {
 
 	wg.Wait()
 	duration := time.Since(startTime)
+	sched := probe.Stop()
 
 	// Count entries using Range (the séance method)
 	var count int64
@@ -140,6 +150,7 @@ func testSyncMap() time.Duration {
 	})
 
 	fmt.Printf("📊 Map size: %d | ⏱️ Time: %v\n", count, duration)
+	fmt.Println(sched.Summary())
 	return duration
 }
 
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func main() {
+	tokenList := flag.String("tokens", "1,8", "comma-separated numbers of tokens in flight")
+	totalHops := flag.Int("hops", 1_000_000, "hops per run, split evenly between the tokens")
+	flag.Parse()
+
+	var tokenCounts []int
+	for _, t := range strings.Split(*tokenList, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(t))
+		if err != nil || n < 1 {
+			panic("Bad -tokens value: " + t)
+		}
+		if n > *totalHops {
+			// hops/tokens would round down to 0 and no token would ever finish
+			fmt.Fprintf(os.Stderr, "-hops %d can't give each of %d tokens a hop\n", *totalHops, n)
+			flag.Usage()
+			os.Exit(2)
+		}
+		tokenCounts = append(tokenCounts, n)
+	}
+
+	fmt.Println("💍 THE TOKEN RING SÉANCE 💍")
+	fmt.Println(strings.Repeat("🕯️", 25))
+
+	original := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(original)
+
+	var procs []int
+	for p := 1; p < runtime.NumCPU(); p *= 2 {
+		procs = append(procs, p)
+	}
+	procs = append(procs, runtime.NumCPU())
+	sizes := []int{2, 10, 100, 1_000, 10_000, 100_000}
+
+	fmt.Printf("\n%d hops per run, each goroutine receives on its channel and sends to its neighbour\n\n", *totalHops)
+	fmt.Printf("%-11s %8s %7s %12s %14s %12s %11s %11s\n",
+		"GOMAXPROCS", "ring", "tokens", "hop latency", "hops/sec", "setup", "delay p50", "delay p99")
+
+	for _, p := range procs {
+		runtime.GOMAXPROCS(p)
+		for _, n := range sizes {
+			for _, tokens := range tokenCounts {
+				if tokens >= n {
+					continue // Every goroutine would be holding a token - nobody left to receive
+				}
+				r := runRing(n, tokens, *totalHops/tokens)
+				fmt.Printf("%-11d %8d %7d %12v %14.0f %12v %11s %11s\n", p, n, tokens,
+					r.hop, float64(r.hops)/r.elapsed.Seconds(), r.setup.Round(time.Microsecond),
+					"≤"+shortDuration(r.sched.DelayPercentile(0.50)), "≤"+shortDuration(r.sched.DelayPercentile(0.99)))
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("💀 With one P every send drops the receiver into runnext and the token never")
+	fmt.Println("leaves the core - the ring size only costs cache misses on cold stacks. More Ps")
+	fmt.Println("means idle Ps wake up and steal, so a lone token pays for futex wake-ups while")
+	fmt.Println("several tokens finally get to run side by side - like Monaleo trading verses")
+	fmt.Println("down a line of rappers instead of passing the mic back and forth.")
+}
+
+type ringResult struct {
+	setup   time.Duration // Spawning the ring, not counted in hop latency
+	elapsed time.Duration
+	hops    int
+	hop     time.Duration // Wall time per hop per token
+	sched   *SchedStats   // Runnable delay while the tokens were moving
+}
+
+// runRing builds a ring of n goroutines joined by unbuffered channels and
+// sends tokens around it until each has made hopsPerToken hops
+func runRing(n, tokens, hopsPerToken int) ringResult {
+	setupStart := time.Now()
+
+	links := make([]chan int, n)
+	for i := range links {
+		links[i] = make(chan int)
+	}
+
+	var done, exited sync.WaitGroup
+	done.Add(tokens)
+	exited.Add(n)
+	for i := 0; i < n; i++ {
+		go func(in, out chan int) {
+			defer exited.Done()
+			for remaining := range in {
+				if remaining == 1 {
+					done.Done() // This token has finished haunting
+					continue
+				}
+				out <- remaining - 1
+			}
+		}(links[i], links[(i+1)%n])
+	}
+	setup := time.Since(setupStart)
+
+	probe := StartSchedProbe()
+	start := time.Now()
+	for t := 0; t < tokens; t++ {
+		links[t*n/tokens] <- hopsPerToken // Spread the tokens evenly around the ring
+	}
+	done.Wait()
+	elapsed := time.Since(start)
+	sched := probe.Stop()
+
+	for _, link := range links {
+		close(link)
+	}
+	exited.Wait()
+
+	hops := tokens * hopsPerToken
+	return ringResult{
+		setup:   setup,
+		elapsed: elapsed,
+		hops:    hops,
+		hop:     elapsed * time.Duration(tokens) / time.Duration(hops),
+		sched:   sched,
+	}
+}
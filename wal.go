@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// A WAL record on disk: 4-byte little-endian payload length, 4-byte CRC32C
+// of the payload, then the payload itself. Payloads are never empty, because
+// an all-zero header - a preallocated or zeroed tail - would otherwise pass
+// as a valid empty record (the CRC32C of nothing is 0).
+const (
+	walHeaderSize = 8
+	walMaxRecord  = 16 << 20 // Anything claiming to be bigger is garbage
+)
+
+var (
+	ErrWALCorrupt = errors.New("wal: corrupt record before the last segment")
+	ErrWALClosed  = errors.New("wal: closed")
+	ErrWALEmpty   = errors.New("wal: empty record")
+)
+
+var walCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// SyncPolicy decides when appended records are forced to disk
+type SyncPolicy int
+
+const (
+	SyncNever    SyncPolicy = iota // Leave it to the page cache (and Close)
+	SyncInterval                   // At most SyncEvery apart, checked on Append
+	SyncAlways                     // Every Append is durable when it returns
+)
+
+// WALConfig sizes segments and picks a sync policy
+type WALConfig struct {
+	SegmentBytes int64         // Rotate once a segment grows past this; defaults to 1MB
+	Sync         SyncPolicy    // When appended records reach the disk
+	SyncEvery    time.Duration // For SyncInterval; defaults to 10ms
+}
+
+// WAL appends length-prefixed, checksummed records to numbered segment
+// files in one directory
+type WAL struct {
+	dir string
+	cfg WALConfig
+
+	mu        sync.Mutex
+	seg       *os.File
+	out       *bufio.Writer
+	segIndex  int
+	segSize   int64
+	lastSync  time.Time
+	frame     []byte // Header plus payload, reused across Appends
+	truncated int64  // Torn-tail bytes cut off by OpenWAL
+	closed    bool
+}
+
+// OpenWAL opens (or creates) the log in dir. Every segment is read once and
+// checked: a torn record at the end of the last segment - a crash
+// mid-append - is truncated away so new records follow the last good one,
+// while a bad record in any earlier segment fails with ErrWALCorrupt, since
+// cutting there would throw away everything logged after it.
+func OpenWAL(dir string, cfg WALConfig) (*WAL, error) {
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = 1 << 20
+	}
+	if cfg.SyncEvery <= 0 {
+		cfg.SyncEvery = 10 * time.Millisecond
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	w := &WAL{dir: dir, cfg: cfg, lastSync: time.Now()}
+	segments, err := walSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return w, w.openSegment(1)
+	}
+
+	// Validate the whole log; the iterator ends on the last segment, with
+	// offset where its good records stop
+	last := segments[len(segments)-1]
+	it := &WALIterator{segments: segments}
+	for it.Next() {
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+	if it.tornBytes > 0 {
+		if err := os.Truncate(last, it.offset); err != nil {
+			return nil, err
+		}
+		w.truncated = it.tornBytes
+	}
+
+	if err := w.openSegment(walSegmentIndex(last)); err != nil {
+		return nil, err
+	}
+	w.segSize = it.offset
+	return w, nil
+}
+
+// Append adds one record and applies the sync policy
+func (w *WAL) Append(payload []byte) error {
+	if len(payload) == 0 {
+		return ErrWALEmpty
+	}
+	if len(payload) > walMaxRecord {
+		return fmt.Errorf("wal: record of %d bytes is over the %d limit", len(payload), walMaxRecord)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrWALClosed
+	}
+
+	w.frame = appendWALRecord(w.frame[:0], payload)
+	if _, err := w.out.Write(w.frame); err != nil {
+		return err
+	}
+	w.segSize += int64(len(w.frame))
+
+	if w.segSize >= w.cfg.SegmentBytes {
+		return w.rotate()
+	}
+	switch {
+	case w.cfg.Sync == SyncAlways,
+		w.cfg.Sync == SyncInterval && time.Since(w.lastSync) >= w.cfg.SyncEvery:
+		return w.syncLocked()
+	}
+	return nil
+}
+
+// Sync flushes buffered records and fsyncs the current segment
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrWALClosed
+	}
+	return w.syncLocked()
+}
+
+// Close syncs and closes the current segment
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.syncLocked()
+	if cerr := w.seg.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Truncated reports how many torn-tail bytes OpenWAL threw away
+func (w *WAL) Truncated() int64 {
+	return w.truncated
+}
+
+// Segments reports how many segment files the log spans
+func (w *WAL) Segments() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	segments, _ := walSegments(w.dir)
+	return len(segments)
+}
+
+func (w *WAL) syncLocked() error {
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	w.lastSync = time.Now()
+	return w.seg.Sync()
+}
+
+// rotate seals the current segment and starts the next one
+func (w *WAL) rotate() error {
+	if err := w.syncLocked(); err != nil {
+		return err
+	}
+	if err := w.seg.Close(); err != nil {
+		return err
+	}
+	return w.openSegment(w.segIndex + 1)
+}
+
+func (w *WAL) openSegment(index int) error {
+	f, err := os.OpenFile(walSegmentPath(w.dir, index), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.seg, w.segIndex, w.segSize = f, index, 0
+	w.out = bufio.NewWriterSize(f, 64<<10)
+	return nil
+}
+
+// appendWALRecord frames payload the way it goes on disk
+func appendWALRecord(dst, payload []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = binary.LittleEndian.AppendUint32(dst, crc32.Checksum(payload, walCRC))
+	return append(dst, payload...)
+}
+
+func walSegmentPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("wal-%08d.log", index))
+}
+
+func walSegmentIndex(path string) int {
+	var index int
+	fmt.Sscanf(filepath.Base(path), "wal-%08d.log", &index)
+	return index
+}
+
+// walSegments lists the segment files in order
+func walSegments(dir string) ([]string, error) {
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(segments) // Zero-padded, so name order is log order
+	return segments, nil
+}
+
+// WALIterator walks every record in every segment, in order. Use it like
+// bufio.Scanner: for it.Next() { it.Record() }, then check it.Err().
+type WALIterator struct {
+	segments []string
+	current  int
+	file     *os.File
+	in       *bufio.Reader
+	offset   int64 // End of the last good record in the current segment
+	record   []byte
+	err      error
+
+	tornBytes int64 // Bytes after the last good record of the final segment
+}
+
+// ReplayWAL starts an iterator over the log in dir
+func ReplayWAL(dir string) *WALIterator {
+	segments, err := walSegments(dir)
+	return &WALIterator{segments: segments, err: err}
+}
+
+// Next advances to the next good record. A bad record in the last segment
+// is a torn tail and ends the replay quietly; anywhere else it's corruption.
+func (it *WALIterator) Next() bool {
+	for it.err == nil {
+		if it.in == nil {
+			if it.current == len(it.segments) {
+				return false
+			}
+			f, err := os.Open(it.segments[it.current])
+			if err != nil {
+				it.err = err
+				return false
+			}
+			it.file, it.in, it.offset = f, bufio.NewReader(f), 0
+		}
+
+		record, err := it.readRecord()
+		if err == nil {
+			it.record = record
+			it.offset += int64(walHeaderSize + len(record))
+			return true
+		}
+
+		last := it.current == len(it.segments)-1
+		if err != io.EOF {
+			if !last {
+				it.err = fmt.Errorf("%w: %s at offset %d", ErrWALCorrupt, it.segments[it.current], it.offset)
+				it.file.Close()
+				return false
+			}
+			if info, serr := it.file.Stat(); serr == nil {
+				it.tornBytes = info.Size() - it.offset
+			}
+		}
+		it.file.Close()
+		it.in = nil
+		it.current++
+		if err != io.EOF {
+			it.current = len(it.segments) // Nothing after a torn tail counts
+		}
+	}
+	return false
+}
+
+// readRecord returns io.EOF at a clean end of segment, and any other error
+// for a short or mangled record
+func (it *WALIterator) readRecord() ([]byte, error) {
+	var header [walHeaderSize]byte
+	if _, err := io.ReadFull(it.in, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size == 0 || size > walMaxRecord {
+		return nil, errors.New("wal: impossible record length")
+	}
+	record := make([]byte, size)
+	if _, err := io.ReadFull(it.in, record); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if crc32.Checksum(record, walCRC) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, errors.New("wal: checksum mismatch")
+	}
+	return record, nil
+}
+
+// Record is the payload Next just read; it stays valid until the next call
+func (it *WALIterator) Record() []byte {
+	return it.record
+}
+
+// Err is the first real error - a torn tail is not one
+func (it *WALIterator) Err() error {
+	return it.err
+}
+
+// TornBytes reports how much of the final segment came after the last
+// good record
+func (it *WALIterator) TornBytes() int64 {
+	return it.tornBytes
+}
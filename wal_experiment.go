@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SafeMap is the same 50x1000 map, except every mutation is written ahead
+// to a WAL before the map sees it
+type SafeMap struct {
+	mu  sync.Mutex
+	m   map[int]int
+	wal *WAL
+}
+
+// A logged mutation: op byte, then varint key (and value for a set)
+const (
+	opSet    = 'S'
+	opDelete = 'D'
+)
+
+// Set logs the insert, then applies it. The lock covers both so the log and
+// the map see mutations in the same order.
+func (sm *SafeMap) Set(key, value int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	record := binary.AppendVarint([]byte{opSet}, int64(key))
+	record = binary.AppendVarint(record, int64(value))
+	if err := sm.wal.Append(record); err != nil {
+		return err
+	}
+	sm.m[key] = value
+	return nil
+}
+
+// Delete logs the removal, then applies it
+func (sm *SafeMap) Delete(key int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if err := sm.wal.Append(binary.AppendVarint([]byte{opDelete}, int64(key))); err != nil {
+		return err
+	}
+	delete(sm.m, key)
+	return nil
+}
+
+func (sm *SafeMap) Get(key int) (int, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	v, ok := sm.m[key]
+	return v, ok
+}
+
+func (sm *SafeMap) Len() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return len(sm.m)
+}
+
+// Close syncs the log; the map is gone once the process is
+func (sm *SafeMap) Close() error {
+	return sm.wal.Close()
+}
+
+// walRecovery is what RecoverSafeMap found on disk
+type walRecovery struct {
+	records   int
+	tornBytes int64
+	segments  int
+	took      time.Duration
+}
+
+// RecoverSafeMap rebuilds the map from the log in dir, cuts off any torn
+// tail, and keeps logging to the same place
+func RecoverSafeMap(dir string, cfg WALConfig) (*SafeMap, walRecovery, error) {
+	var rec walRecovery
+	start := time.Now()
+	sm := &SafeMap{m: make(map[int]int)}
+
+	it := ReplayWAL(dir)
+	for it.Next() {
+		if err := sm.apply(it.Record()); err != nil {
+			return nil, rec, err
+		}
+		rec.records++
+	}
+	if err := it.Err(); err != nil {
+		return nil, rec, err
+	}
+
+	wal, err := OpenWAL(dir, cfg)
+	if err != nil {
+		return nil, rec, err
+	}
+	sm.wal = wal
+	rec.tornBytes = wal.Truncated()
+	rec.segments = wal.Segments()
+	rec.took = time.Since(start)
+	return sm, rec, nil
+}
+
+// apply replays one logged mutation without logging it again
+func (sm *SafeMap) apply(record []byte) error {
+	if len(record) == 0 {
+		return errors.New("wal: empty mutation")
+	}
+	key, n := binary.Varint(record[1:])
+	if n <= 0 {
+		return errors.New("wal: bad key in mutation")
+	}
+	switch record[0] {
+	case opSet:
+		value, m := binary.Varint(record[1+n:])
+		if m <= 0 {
+			return errors.New("wal: bad value in mutation")
+		}
+		sm.m[int(key)] = int(value)
+	case opDelete:
+		delete(sm.m, int(key))
+	default:
+		return fmt.Errorf("wal: unknown op %q", record[0])
+	}
+	return nil
+}
+
+// walPolicy is one sync policy for the throughput table
+type walPolicy struct {
+	name string
+	cfg  WALConfig
+}
+
+func main() {
+	child := flag.Bool("child", false, "internal: write keys into -wal until killed, printing each durable one")
+	walDir := flag.String("wal", "", "internal: log directory for -child")
+	dir := flag.String("dir", ".", "where the scratch logs go")
+	writers := flag.Int("writers", 50, "goroutines inserting into the map")
+	inserts := flag.Int("inserts", 1000, "inserts per goroutine")
+	durable := flag.Int("durable-inserts", 100, "inserts per goroutine under SyncAlways (0 skips it)")
+	crashes := flag.Int("crashes", 6, "kill-and-recover rounds (0 skips them)")
+	flag.Parse()
+
+	if *child {
+		runCrashChild(*walDir)
+		return
+	}
+
+	fmt.Println("📓 THE NECRONOMICON WRITES ITSELF FIRST 📓")
+	fmt.Println(strings.Repeat("🕯️", 25))
+
+	policies := []walPolicy{
+		{"SyncNever", WALConfig{Sync: SyncNever}},
+		{"SyncInterval 10ms", WALConfig{Sync: SyncInterval, SyncEvery: 10 * time.Millisecond}},
+		{"SyncAlways", WALConfig{Sync: SyncAlways}},
+	}
+	fmt.Printf("\n%d writers into a logged SafeMap, 1MB segments, then recover it from disk\n\n", *writers)
+	fmt.Printf("%-18s %8s %12s %12s %9s %12s  %s\n",
+		"policy", "inserts", "time", "inserts/sec", "segments", "replay", "verdict")
+	failed := false
+	for _, p := range policies {
+		perWriter := *inserts
+		if p.cfg.Sync == SyncAlways {
+			perWriter = *durable
+		}
+		if perWriter == 0 {
+			continue
+		}
+		if !runPolicy(*dir, p, *writers, perWriter) {
+			failed = true
+		}
+	}
+
+	if *crashes > 0 {
+		fmt.Println("\n💀 CRASH SÉANCE: a child inserts key i -> 7i under SyncAlways and prints i once")
+		fmt.Println("Append returns. We SIGKILL it mid-write, sometimes tear the tail, then recover.")
+		fmt.Println()
+		if !runCrashTrials(*dir, *crashes) {
+			failed = true
+		}
+	}
+
+	fmt.Println("\n💀 Every mutation hits the log before the map, so whatever the map acknowledged")
+	fmt.Println("can be rebuilt - as long as it reached the disk. SyncAlways pays an fsync per")
+	fmt.Println("insert for that; SyncNever trusts the page cache and the kernel's good mood.")
+	fmt.Println("A torn tail is just the last sentence of a diary cut off mid-word - Replay stops")
+	fmt.Println("at the last whole record, like Chilling Adventures of Sabrina picking up where")
+	fmt.Println("the previous witch's grimoire ends.")
+
+	if failed {
+		fmt.Println("\n❌ At least one recovery came back wrong")
+		os.Exit(1)
+	}
+}
+
+// runPolicy inserts writers*perWriter keys under one policy, closes the log,
+// and checks a fresh map recovered from it. It reports whether the check
+// passed.
+func runPolicy(dir string, p walPolicy, writers, perWriter int) bool {
+	logDir, err := os.MkdirTemp(dir, "wal-policy-")
+	if err != nil {
+		panic("No room for the tome: " + err.Error())
+	}
+	defer os.RemoveAll(logDir)
+
+	sm, _, err := RecoverSafeMap(logDir, p.cfg)
+	if err != nil {
+		panic("Could not open the log: " + err.Error())
+	}
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := sm.Set(id*perWriter+i, i); err != nil {
+					panic("Set failed: " + err.Error())
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := sm.Close(); err != nil {
+		panic("Close failed: " + err.Error())
+	}
+	elapsed := time.Since(start)
+
+	recovered, rec, err := RecoverSafeMap(logDir, p.cfg)
+	if err != nil {
+		panic("Recovery failed: " + err.Error())
+	}
+	defer recovered.Close()
+
+	n := writers * perWriter
+	ok, verdict := true, fmt.Sprintf("✅ %d keys back", recovered.Len())
+	for k := 0; k < n; k++ {
+		if v, present := recovered.Get(k); !present || v != k%perWriter {
+			ok, verdict = false, fmt.Sprintf("❌ key %d came back as %d (present %v)", k, v, present)
+			break
+		}
+	}
+	if recovered.Len() != n {
+		ok, verdict = false, fmt.Sprintf("❌ %d keys back, wanted %d", recovered.Len(), n)
+	}
+
+	fmt.Printf("%-18s %8d %12v %12.0f %9d %12v  %s\n", p.name, n, elapsed.Round(time.Microsecond),
+		float64(n)/elapsed.Seconds(), rec.segments, rec.took.Round(time.Microsecond), verdict)
+	return ok
+}
+
+// crashWAL keeps segments small so the crash rounds cross a few rotations
+var crashWAL = WALConfig{SegmentBytes: 64 << 10, Sync: SyncAlways}
+
+// runCrashChild picks up wherever the last victim left off and inserts
+// until it is killed. A key is printed only after Set returns, so every
+// printed key is on disk.
+func runCrashChild(dir string) {
+	sm, _, err := RecoverSafeMap(dir, crashWAL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "child recovery failed:", err)
+		os.Exit(1)
+	}
+	for key := sm.Len(); ; key++ {
+		if err := sm.Set(key, 7*key); err != nil {
+			fmt.Fprintln(os.Stderr, "child Set failed:", err)
+			os.Exit(1)
+		}
+		fmt.Println(key) // os.Stdout is unbuffered - one write per ack
+	}
+}
+
+// tearKinds is what happens to the log after each kill, in rotation. These
+// records are small enough that a SIGKILL never lands inside one - the
+// kernel finishes the write(2) - so the other three fake what power loss
+// mid-write leaves behind. wal_test.go kills a child inside multi-megabyte
+// writes for a real tear.
+var tearKinds = []string{"kill only", "half a record", "bad checksum", "zeroed tail"}
+
+// runCrashTrials kills the child mid-write over and over on one log, and
+// checks that each recovery keeps every acknowledged key and nothing else.
+// It reports whether every round passed.
+func runCrashTrials(dir string, trials int) bool {
+	self, err := os.Executable()
+	if err != nil {
+		panic("Lost our own body: " + err.Error())
+	}
+	logDir, err := os.MkdirTemp(dir, "wal-crash-")
+	if err != nil {
+		panic("No room for the tome: " + err.Error())
+	}
+	defer os.RemoveAll(logDir)
+
+	passed := true
+	fmt.Printf("%-6s %-14s %9s %10s %10s %10s %9s  %s\n",
+		"round", "damage", "acked", "recovered", "unacked", "torn B", "segments", "verdict")
+	for trial := 0; trial < trials; trial++ {
+		cmd := exec.Command(self, "-child", "-wal", logDir)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			panic("No pipe to the child: " + err.Error())
+		}
+		if err := cmd.Start(); err != nil {
+			panic("Child séance failed: " + err.Error())
+		}
+
+		// Highest key the child swore was durable
+		var acked atomic.Int64
+		acked.Store(-1)
+		reading := make(chan struct{})
+		go func() {
+			defer close(reading)
+			scanner := bufio.NewScanner(out)
+			for scanner.Scan() {
+				if key, err := strconv.Atoi(scanner.Text()); err == nil {
+					acked.Store(int64(key))
+				}
+			}
+		}()
+
+		time.Sleep(time.Duration(50+rand.IntN(150)) * time.Millisecond)
+		cmd.Process.Kill() // SIGKILL - no deferred Close, no last flush
+		<-reading
+		cmd.Wait()
+
+		kind := tearKinds[trial%len(tearKinds)]
+		injected := tearTail(logDir, kind)
+
+		sm, rec, err := RecoverSafeMap(logDir, crashWAL)
+		if err != nil {
+			fmt.Printf("%-6d %-14s %9d %10s %10s %10s %9s  ❌ recovery failed: %v\n",
+				trial+1, kind, acked.Load()+1, "-", "-", "-", "-", err)
+			passed = false
+			continue
+		}
+		ok, verdict := checkCrashRecovery(sm, int(acked.Load()), rec.tornBytes, injected)
+		passed = passed && ok
+		fmt.Printf("%-6d %-14s %9d %10d %10d %10d %9d  %s\n", trial+1, kind, acked.Load()+1,
+			sm.Len(), sm.Len()-int(acked.Load()+1), rec.tornBytes, rec.segments, verdict)
+		sm.Close()
+	}
+	return passed
+}
+
+// tearTail appends what a write cut short would leave at the end of the
+// last segment, and returns how many bytes that was
+func tearTail(dir, kind string) int64 {
+	segments, err := walSegments(dir)
+	if err != nil || len(segments) == 0 {
+		return 0
+	}
+	record := appendWALRecord(nil, binary.AppendVarint([]byte{opSet}, 666_666))
+	switch kind {
+	case "half a record":
+		record = record[:len(record)/2]
+	case "bad checksum":
+		record[len(record)-1] ^= 0xff
+	case "zeroed tail":
+		record = make([]byte, 4096) // What a preallocated segment looks like
+	default:
+		return 0
+	}
+
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		panic("Could not deface the log: " + err.Error())
+	}
+	defer f.Close()
+	if _, err := f.Write(record); err != nil {
+		panic("Could not deface the log: " + err.Error())
+	}
+	return int64(len(record))
+}
+
+// checkCrashRecovery wants keys 0..n-1 mapped to 7k with no gaps, every
+// acknowledged key among them, and exactly the injected garbage cut off
+func checkCrashRecovery(sm *SafeMap, acked int, tornBytes, injected int64) (bool, string) {
+	n := sm.Len()
+	for k := 0; k < n; k++ {
+		if v, ok := sm.Get(k); !ok || v != 7*k {
+			return false, fmt.Sprintf("❌ key %d came back as %d (present %v)", k, v, ok)
+		}
+	}
+	switch {
+	case n <= acked:
+		return false, fmt.Sprintf("❌ lost acknowledged keys %d..%d", n, acked)
+	case tornBytes != injected:
+		return false, fmt.Sprintf("❌ cut %dB, expected %dB", tornBytes, injected)
+	}
+	return true, "✅ every ack survived, tail clean"
+}
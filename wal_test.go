@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// Run with: go test wal.go wal_test.go
+
+// writeRecords appends n records, record i being "record-i"
+func writeRecords(t *testing.T, dir string, cfg WALConfig, n int) {
+	t.Helper()
+	w, err := OpenWAL(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := w.Append(testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRecord(i int) []byte {
+	return binary.AppendVarint([]byte("record-"), int64(i))
+}
+
+// replayAll returns every record Replay yields, failing on a real error
+func replayAll(t *testing.T, dir string) ([][]byte, int64) {
+	t.Helper()
+	var records [][]byte
+	it := ReplayWAL(dir)
+	for it.Next() {
+		records = append(records, bytes.Clone(it.Record()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	return records, it.TornBytes()
+}
+
+func checkSequence(t *testing.T, records [][]byte, want int) {
+	t.Helper()
+	if len(records) != want {
+		t.Fatalf("replayed %d records, want %d", len(records), want)
+	}
+	for i, r := range records {
+		if !bytes.Equal(r, testRecord(i)) {
+			t.Fatalf("record %d = %q, want %q", i, r, testRecord(i))
+		}
+	}
+}
+
+func appendToLastSegment(t *testing.T, dir string, tail []byte) {
+	t.Helper()
+	segments, err := walSegments(dir)
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("no segments: %v", err)
+	}
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(tail); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayStopsAtDamagedTail(t *testing.T) {
+	whole := appendWALRecord(nil, testRecord(999))
+	badCRC := bytes.Clone(whole)
+	badCRC[len(badCRC)-1] ^= 0xff
+
+	tails := []struct {
+		name string
+		tail []byte
+	}{
+		{"torn header", whole[:walHeaderSize/2]},
+		{"torn payload", whole[:len(whole)-2]},
+		{"bad checksum", badCRC},
+		{"zeroed tail", make([]byte, 4096)},
+		{"impossible length", []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}},
+	}
+	for _, tc := range tails {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRecords(t, dir, WALConfig{}, 100)
+			appendToLastSegment(t, dir, tc.tail)
+
+			records, torn := replayAll(t, dir)
+			checkSequence(t, records, 100)
+			if torn != int64(len(tc.tail)) {
+				t.Fatalf("TornBytes = %d, want %d", torn, len(tc.tail))
+			}
+
+			// Opening cuts the tail off, and new records replay after the old
+			w, err := OpenWAL(dir, WALConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.Truncated() != int64(len(tc.tail)) {
+				t.Fatalf("Truncated = %d, want %d", w.Truncated(), len(tc.tail))
+			}
+			if err := w.Append(testRecord(100)); err != nil {
+				t.Fatal(err)
+			}
+			w.Close()
+
+			records, torn = replayAll(t, dir)
+			checkSequence(t, records, 101)
+			if torn != 0 {
+				t.Fatalf("TornBytes after reopen = %d, want 0", torn)
+			}
+		})
+	}
+}
+
+func TestRotationReplaysInOrder(t *testing.T) {
+	dir := t.TempDir()
+	cfg := WALConfig{SegmentBytes: 256}
+	writeRecords(t, dir, cfg, 500)
+	writeRecords(t, dir, cfg, 0) // Reopen and close without appending
+
+	segments, _ := walSegments(dir)
+	if len(segments) < 10 {
+		t.Fatalf("only %d segments, want rotation", len(segments))
+	}
+	records, _ := replayAll(t, dir)
+	checkSequence(t, records, 500)
+}
+
+func TestCorruptEarlierSegment(t *testing.T) {
+	dir := t.TempDir()
+	writeRecords(t, dir, WALConfig{SegmentBytes: 256}, 100)
+
+	segments, _ := walSegments(dir)
+	data, err := os.ReadFile(segments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[walHeaderSize] ^= 0xff // First payload byte of the first record
+	if err := os.WriteFile(segments[0], data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	it := ReplayWAL(dir)
+	for it.Next() {
+	}
+	if !errors.Is(it.Err(), ErrWALCorrupt) {
+		t.Fatalf("replay error = %v, want ErrWALCorrupt", it.Err())
+	}
+	if _, err := OpenWAL(dir, WALConfig{}); !errors.Is(err, ErrWALCorrupt) {
+		t.Fatalf("OpenWAL error = %v, want ErrWALCorrupt", err)
+	}
+}
+
+func TestAppendRejectsEmpty(t *testing.T) {
+	w, err := OpenWAL(t.TempDir(), WALConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Append(nil); !errors.Is(err, ErrWALEmpty) {
+		t.Fatalf("Append(nil) = %v, want ErrWALEmpty", err)
+	}
+}
+
+// bigRecordSize makes each Append one multi-megabyte write(2). The kernel
+// checks for a fatal signal between pages, so a SIGKILL can land inside it
+// and leave a genuinely torn record.
+const bigRecordSize = 4 << 20
+
+func bigRecord(i int) []byte {
+	record := bytes.Repeat([]byte{byte(i)}, bigRecordSize)
+	binary.LittleEndian.PutUint64(record, uint64(i))
+	return record
+}
+
+// TestKillMidWrite re-runs the test binary as a child that appends big
+// records until it is SIGKILLed, then checks that recovery keeps a clean
+// prefix of whole records and truncates whatever the kill cut short
+func TestKillMidWrite(t *testing.T) {
+	if dir := os.Getenv("WAL_CRASH_CHILD"); dir != "" {
+		w, err := OpenWAL(dir, WALConfig{SegmentBytes: 64 << 20})
+		if err != nil {
+			os.Exit(1)
+		}
+		for i := 0; ; i++ {
+			if err := w.Append(bigRecord(i)); err != nil {
+				os.Exit(1)
+			}
+		}
+	}
+	if testing.Short() {
+		t.Skip("spawns child processes")
+	}
+
+	tears := 0
+	for round := 0; round < 5; round++ {
+		dir := t.TempDir()
+		cmd := exec.Command(os.Args[0], "-test.run=^TestKillMidWrite$")
+		cmd.Env = append(os.Environ(), "WAL_CRASH_CHILD="+dir)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Let a few records land, then kill it somewhere in the next ones
+		deadline := time.Now().Add(10 * time.Second)
+		for logBytes(dir) < 4*bigRecordSize && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(time.Duration(round) * time.Millisecond)
+		cmd.Process.Kill()
+		cmd.Wait()
+
+		it := ReplayWAL(dir)
+		n := 0
+		for it.Next() {
+			if !bytes.Equal(it.Record(), bigRecord(n)) {
+				t.Fatalf("round %d: record %d is not what was appended", round, n)
+			}
+			n++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("round %d: replay: %v", round, err)
+		}
+		if n < 3 {
+			t.Fatalf("round %d: only %d whole records survived", round, n)
+		}
+		if it.TornBytes() > 0 {
+			tears++
+		}
+
+		w, err := OpenWAL(dir, WALConfig{SegmentBytes: 64 << 20})
+		if err != nil {
+			t.Fatalf("round %d: reopen: %v", round, err)
+		}
+		if w.Truncated() != it.TornBytes() {
+			t.Fatalf("round %d: truncated %d, replay saw %d torn", round, w.Truncated(), it.TornBytes())
+		}
+		w.Close()
+	}
+	t.Logf("%d of 5 kills tore a record mid-write", tears)
+}
+
+func logBytes(dir string) int64 {
+	segments, _ := walSegments(dir)
+	var total int64
+	for _, s := range segments {
+		if info, err := os.Stat(s); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
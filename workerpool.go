@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RejectPolicy decides what Submit does when the queue is full
+type RejectPolicy int
+
+const (
+	RejectBlock      RejectPolicy = iota // Wait for room (or ctx/shutdown)
+	RejectDrop                           // Give up with ErrPoolFull
+	RejectCallerRuns                     // Run the task on the submitting goroutine
+)
+
+var (
+	ErrPoolFull   = errors.New("worker pool: queue full")
+	ErrPoolClosed = errors.New("worker pool: shut down")
+)
+
+// PoolConfig sizes a WorkerPool. MaxWorkers > MinWorkers makes it elastic:
+// extra workers are summoned when the queue backs up and banished after
+// IdleTimeout without work.
+type PoolConfig struct {
+	MinWorkers  int
+	MaxWorkers  int
+	QueueSize   int
+	Policy      RejectPolicy
+	IdleTimeout time.Duration // Defaults to one second
+	OnPanic     func(v any)   // Called with whatever a task panicked with
+}
+
+// PoolStats is a snapshot of what the pool has done so far
+type PoolStats struct {
+	Submitted   uint64
+	Completed   uint64 // Includes tasks that panicked
+	Dropped     uint64 // Rejected with ErrPoolFull
+	CallerRan   uint64 // Run by the submitter under RejectCallerRuns
+	Cancelled   uint64 // Skipped because their ctx was done before they started
+	Panicked    uint64
+	Workers     int32
+	PeakWorkers int32
+}
+
+type poolTask struct {
+	ctx context.Context
+	fn  func(ctx context.Context)
+}
+
+// WorkerPool runs submitted tasks on a bounded set of goroutines
+type WorkerPool struct {
+	cfg   PoolConfig
+	tasks chan poolTask
+	quit  chan struct{} // Closed first on shutdown to free blocked submitters
+
+	mu     sync.RWMutex // Submit holds it shared so Shutdown can close tasks safely
+	closed bool
+	once   sync.Once
+	wg     sync.WaitGroup
+
+	workers   atomic.Int32
+	peak      atomic.Int32
+	idle      atomic.Int32
+	submitted atomic.Uint64
+	completed atomic.Uint64
+	dropped   atomic.Uint64
+	callerRan atomic.Uint64
+	cancelled atomic.Uint64
+	panicked  atomic.Uint64
+}
+
+// NewWorkerPool starts MinWorkers workers right away
+func NewWorkerPool(cfg PoolConfig) *WorkerPool {
+	cfg.MinWorkers = max(cfg.MinWorkers, 1)
+	cfg.MaxWorkers = max(cfg.MaxWorkers, cfg.MinWorkers)
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = time.Second
+	}
+
+	p := &WorkerPool{
+		cfg:   cfg,
+		tasks: make(chan poolTask, cfg.QueueSize),
+		quit:  make(chan struct{}),
+	}
+	for i := 0; i < cfg.MinWorkers; i++ {
+		p.spawn(true)
+	}
+	return p
+}
+
+// Submit queues fn to run with ctx. If ctx is done before a worker picks
+// the task up, the task is skipped.
+func (p *WorkerPool) Submit(ctx context.Context, fn func(ctx context.Context)) error {
+	t := poolTask{ctx: ctx, fn: fn}
+	p.mu.RLock()
+	callerRuns, err := p.enqueue(t)
+	p.mu.RUnlock()
+
+	// Run inline only after letting go of the lock: a long task would stall
+	// Shutdown, and one that calls Shutdown itself would deadlock. enqueue
+	// already counted it in p.wg, so Shutdown still waits for it.
+	if callerRuns {
+		p.callerRan.Add(1)
+		p.run(t)
+		p.wg.Done()
+	}
+	return err
+}
+
+// enqueue hands t to the workers, or reports that RejectCallerRuns wants
+// the submitter to run it - already added to p.wg, which must happen under
+// p.mu so Shutdown can't start waiting first. The caller holds p.mu shared.
+func (p *WorkerPool) enqueue(t poolTask) (callerRuns bool, err error) {
+	if p.closed {
+		return false, ErrPoolClosed
+	}
+	if err := t.ctx.Err(); err != nil {
+		return false, err
+	}
+	p.submitted.Add(1)
+
+	select {
+	case p.tasks <- t:
+		p.growIfBackedUp()
+		return false, nil
+	default:
+	}
+
+	// Queue is full - summon help before falling back on the policy
+	if p.spawn(false) {
+		select {
+		case p.tasks <- t:
+			return false, nil
+		default:
+		}
+	}
+
+	switch p.cfg.Policy {
+	case RejectDrop:
+		p.dropped.Add(1)
+		return false, ErrPoolFull
+	case RejectCallerRuns:
+		p.wg.Add(1)
+		return true, nil
+	default:
+		select {
+		case p.tasks <- t:
+			return false, nil
+		case <-t.ctx.Done():
+			return false, t.ctx.Err()
+		case <-p.quit:
+			return false, ErrPoolClosed
+		}
+	}
+}
+
+// growIfBackedUp adds a worker when work is waiting and nobody is idle
+func (p *WorkerPool) growIfBackedUp() {
+	if p.idle.Load() == 0 && len(p.tasks) > 0 {
+		p.spawn(false)
+	}
+}
+
+// spawn starts a worker if the pool is below MaxWorkers. Core workers
+// live until shutdown; the rest leave after IdleTimeout without work.
+func (p *WorkerPool) spawn(core bool) bool {
+	for {
+		n := p.workers.Load()
+		if n >= int32(p.cfg.MaxWorkers) {
+			return false
+		}
+		if p.workers.CompareAndSwap(n, n+1) {
+			for {
+				peak := p.peak.Load()
+				if n+1 <= peak || p.peak.CompareAndSwap(peak, n+1) {
+					break
+				}
+			}
+			p.wg.Add(1)
+			go p.worker(core)
+			return true
+		}
+	}
+}
+
+func (p *WorkerPool) worker(core bool) {
+	defer p.wg.Done()
+	defer p.workers.Add(-1)
+
+	var idleTimer *time.Timer
+	var idleC <-chan time.Time
+	if !core {
+		idleTimer = time.NewTimer(p.cfg.IdleTimeout)
+		defer idleTimer.Stop()
+		idleC = idleTimer.C
+	}
+
+	for {
+		p.idle.Add(1)
+		select {
+		case t, ok := <-p.tasks:
+			p.idle.Add(-1)
+			if !ok {
+				return // Closed and drained
+			}
+			p.run(t)
+			if idleTimer != nil {
+				idleTimer.Reset(p.cfg.IdleTimeout)
+			}
+		case <-idleC:
+			p.idle.Add(-1)
+			return // Nothing to haunt - fade away
+		}
+	}
+}
+
+// run executes one task, surviving whatever it panics with
+func (p *WorkerPool) run(t poolTask) {
+	if t.ctx.Err() != nil {
+		p.cancelled.Add(1)
+		return
+	}
+	defer func() {
+		p.completed.Add(1)
+		if v := recover(); v != nil {
+			p.panicked.Add(1)
+			if p.cfg.OnPanic != nil {
+				p.cfg.OnPanic(v)
+			}
+		}
+	}()
+	t.fn(t.ctx)
+}
+
+// Shutdown stops accepting tasks and waits for queued and running ones to
+// finish, including any a submitter is running under RejectCallerRuns. It
+// returns ctx.Err() if ctx ends first; workers keep draining. A task that
+// calls Shutdown ends up waiting for itself, so it needs a ctx that ends.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.once.Do(func() {
+		close(p.quit)
+		p.mu.Lock() // Waits out any Submit mid-send
+		p.closed = true
+		close(p.tasks)
+		p.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the pool's counters
+func (p *WorkerPool) Stats() PoolStats {
+	return PoolStats{
+		Submitted:   p.submitted.Load(),
+		Completed:   p.completed.Load(),
+		Dropped:     p.dropped.Load(),
+		CallerRan:   p.callerRan.Load(),
+		Cancelled:   p.cancelled.Load(),
+		Panicked:    p.panicked.Load(),
+		Workers:     p.workers.Load(),
+		PeakWorkers: p.peak.Load(),
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Regular Mutex Map - the same 50x1000 target as every other experiment
+type SafeMap struct {
+	mu sync.Mutex
+	m  map[int]int
+}
+
+func (sm *SafeMap) Set(key, value int) {
+	sm.mu.Lock()
+	sm.m[key] = value
+	sm.mu.Unlock()
+}
+
+func (sm *SafeMap) Len() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return len(sm.m)
+}
+
+type poolTrial struct {
+	name string
+	run  func(sm *SafeMap) (time.Duration, string)
+}
+
+func main() {
+	fmt.Println("🧟 THE WORKER POOL CRYPT 🧟")
+	fmt.Println(strings.Repeat("🕯️", 25))
+
+	cpus := runtime.GOMAXPROCS(0)
+	fmt.Printf("\nGOMAXPROCS=%d, 50 writers x 1000 inserts, mean of 3 runs\n", cpus)
+
+	trials := []poolTrial{
+		{"goroutine per writer (50 x 1000)", func(sm *SafeMap) (time.Duration, string) {
+			return spawnPerTask(sm, 50, 1000), ""
+		}},
+		{"pool, writer tasks (50 x 1000)", func(sm *SafeMap) (time.Duration, string) {
+			return poolPerTask(sm, PoolConfig{MinWorkers: cpus, QueueSize: 64}, 50, 1000)
+		}},
+		{"goroutine per insert (50000 x 1)", func(sm *SafeMap) (time.Duration, string) {
+			return spawnPerTask(sm, 50000, 1), ""
+		}},
+		{"pool, block (50000 x 1)", func(sm *SafeMap) (time.Duration, string) {
+			return poolPerTask(sm, PoolConfig{MinWorkers: cpus, QueueSize: 1024}, 50000, 1)
+		}},
+		{"pool, elastic (50000 x 1)", func(sm *SafeMap) (time.Duration, string) {
+			return poolPerTask(sm, PoolConfig{MinWorkers: 1, MaxWorkers: 4 * cpus, QueueSize: 64}, 50000, 1)
+		}},
+		{"pool, caller-runs (50000 x 1)", func(sm *SafeMap) (time.Duration, string) {
+			return poolPerTask(sm, PoolConfig{MinWorkers: cpus, QueueSize: 16, Policy: RejectCallerRuns}, 50000, 1)
+		}},
+		{"pool, drop (50000 x 1)", func(sm *SafeMap) (time.Duration, string) {
+			return poolPerTask(sm, PoolConfig{MinWorkers: cpus, QueueSize: 16, Policy: RejectDrop}, 50000, 1)
+		}},
+	}
+
+	fmt.Printf("\n%-34s %12s %10s  %s\n", "strategy", "mean time", "len(m)", "pool stats (last run)")
+	for _, t := range trials {
+		var total time.Duration
+		var size int
+		var note string
+		for run := 0; run < 3; run++ {
+			sm := &SafeMap{m: make(map[int]int)}
+			d, n := t.run(sm)
+			total += d
+			size, note = sm.Len(), n
+		}
+		fmt.Printf("%-34s %12v %10d  %s\n", t.name, total/3, size, note)
+	}
+
+	fmt.Println("\n🔮 THE POOL'S PROTECTIVE WARDS 🔮")
+	demoPoolWards()
+
+	fmt.Println("\n💀 A goroutine per insert summons 50,000 spirits for a 100ns job.")
+	fmt.Println("The pool keeps a fixed coven and hands them work - like Wu Zetian's")
+	fmt.Println("pilots taking turns in the same mech instead of building a new one each fight.")
+}
+
+// spawnPerTask is how every other experiment does it: wg.Add(1); go func
+func spawnPerTask(sm *SafeMap, tasks, insertsPerTask int) time.Duration {
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < tasks; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < insertsPerTask; i++ {
+				sm.Set(id*insertsPerTask+i, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+// poolPerTask submits the same work to a WorkerPool and drains it
+func poolPerTask(sm *SafeMap, cfg PoolConfig, tasks, insertsPerTask int) (time.Duration, string) {
+	ctx := context.Background()
+	start := time.Now()
+
+	pool := NewWorkerPool(cfg)
+	for g := 0; g < tasks; g++ {
+		id := g
+		err := pool.Submit(ctx, func(context.Context) {
+			for i := 0; i < insertsPerTask; i++ {
+				sm.Set(id*insertsPerTask+i, i)
+			}
+		})
+		if err != nil && !errors.Is(err, ErrPoolFull) {
+			panic("Submit failed: " + err.Error())
+		}
+	}
+	if err := pool.Shutdown(ctx); err != nil {
+		panic("Shutdown failed: " + err.Error())
+	}
+	duration := time.Since(start)
+
+	s := pool.Stats()
+	return duration, fmt.Sprintf("peak workers %d, caller ran %d, dropped %d",
+		s.PeakWorkers, s.CallerRan, s.Dropped)
+}
+
+// demoPoolWards shows panic recovery, per-task cancellation and a Shutdown
+// that gives up waiting
+func demoPoolWards() {
+	pool := NewWorkerPool(PoolConfig{
+		MinWorkers: 2,
+		QueueSize:  8,
+		OnPanic: func(v any) {
+			fmt.Printf("🩸 Recovered a task that screamed: %v\n", v)
+		},
+	})
+
+	pool.Submit(context.Background(), func(context.Context) {
+		panic("the call is coming from inside the house")
+	})
+
+	// Occupy both workers so the next task waits in the queue
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		pool.Submit(context.Background(), func(context.Context) { <-release })
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Submit(ctx, func(context.Context) {
+		fmt.Println("👻 This task should never run")
+	})
+	cancel() // Cancelled while still queued - the worker will skip it
+
+	// One task that outlives the shutdown deadline
+	pool.Submit(context.Background(), func(context.Context) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	close(release)
+
+	shutdownCtx, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	fmt.Printf("⏳ Shutdown with a 50ms deadline: %v\n", pool.Shutdown(shutdownCtx))
+	fmt.Printf("⏳ Shutdown again, no deadline: %v\n", pool.Shutdown(context.Background()))
+
+	if err := pool.Submit(context.Background(), func(context.Context) {}); err != nil {
+		fmt.Printf("🚪 Submit after shutdown: %v\n", err)
+	}
+
+	s := pool.Stats()
+	fmt.Printf("📊 submitted %d, completed %d, cancelled %d, panicked %d\n",
+		s.Submitted, s.Completed, s.Cancelled, s.Panicked)
+}